@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/prem0x01/ncCmdExe/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchListen    bool
+	benchPort      int
+	benchUDP       bool
+	benchDuration  time.Duration
+	benchParallel  int
+	benchBuffer    string
+	benchReverse   bool
+	benchBandwidth string
+	benchJSON      bool
+	benchTimeout   int
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [host]",
+	Short: "Measure throughput between two instances",
+	Long: `Measure throughput between two instances, like iperf.
+
+Start a server with "ncCmdExe bench -l", then run "ncCmdExe bench <host>"
+on the other end. By default the client sends; -R makes the server send.
+UDP runs also report jitter, loss and reordering, and are paced at
+--bandwidth (1M bytes/sec per stream unless set).`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchListen {
+			network := "tcp"
+			if benchUDP {
+				network = "udp"
+			}
+			server := core.NewServerWithConfig(core.ServerConfig{
+				Network: network,
+				Address: fmt.Sprintf(":%d", benchPort),
+				Bench:   true,
+			})
+			server.Start()
+			return
+		}
+
+		if len(args) == 0 {
+			fmt.Println("Error: host is required unless --listen is set")
+			os.Exit(1)
+		}
+
+		buffer := benchBuffer
+		if buffer == "" {
+			buffer = "128k"
+			if benchUDP {
+				buffer = "1470"
+			}
+		}
+		bufferSize, err := utils.ParseByteSize(buffer)
+		if err != nil {
+			fmt.Printf("Error: --buffer: %v\n", err)
+			os.Exit(1)
+		}
+
+		bandwidth := benchBandwidth
+		if bandwidth == "" && benchUDP {
+			bandwidth = "1M"
+		}
+		var rate int64
+		if bandwidth != "" {
+			rate, err = utils.ParseByteSize(bandwidth)
+			if err != nil {
+				fmt.Printf("Error: --bandwidth: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		client := core.NewClient(args[0], benchPort, benchUDP, benchTimeout)
+		result, err := client.Bench(core.BenchConfig{
+			Duration:   benchDuration,
+			Streams:    benchParallel,
+			BufferSize: int(bufferSize),
+			Reverse:    benchReverse,
+			Rate:       rate,
+		})
+
+		if benchJSON && result != nil {
+			out, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(out))
+		} else if result != nil {
+			core.PrintBenchResult(os.Stdout, result)
+		}
+		if err != nil {
+			if !benchJSON {
+				fmt.Printf("Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	benchCmd.Flags().BoolVarP(&benchListen, "listen", "l", false, "Run as the benchmark server")
+	benchCmd.Flags().IntVarP(&benchPort, "port", "p", 5201, "Port number")
+	benchCmd.Flags().BoolVarP(&benchUDP, "udp", "u", false, "Use UDP instead of TCP")
+	benchCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 10*time.Second, "How long to send for")
+	benchCmd.Flags().IntVarP(&benchParallel, "parallel", "P", 1, "Number of parallel streams")
+	benchCmd.Flags().StringVarP(&benchBuffer, "buffer", "b", "", "Write size, e.g. 64k (default 128k for TCP, 1470 for UDP)")
+	benchCmd.Flags().BoolVarP(&benchReverse, "reverse", "R", false, "Have the server send and the client receive")
+	benchCmd.Flags().StringVar(&benchBandwidth, "bandwidth", "", "Limit each stream to this many bytes/sec (e.g. 10M)")
+	benchCmd.Flags().BoolVar(&benchJSON, "json", false, "Print the result as JSON")
+	benchCmd.Flags().IntVarP(&benchTimeout, "timeout", "t", 5, "Connection timeout in seconds")
+	rootCmd.AddCommand(benchCmd)
+}
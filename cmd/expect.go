@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	expectPort    int
+	expectTimeout int
+)
+
+var expectCmd = &cobra.Command{
+	Use:   "expect <script> <host>",
+	Short: "Run a send/expect script against a TCP service",
+	Long: `Run a send/expect script against a TCP service and exit 0 if every
+step passes, 1 otherwise. --verbose shows every send and match.
+
+Script steps, one per line (# starts a comment):
+
+	timeout 5s              timeout for following expects (default 10s)
+	expect /regex/          wait for regex; "quoted" regexes also work
+	send "text\r\n"         Go-quoted; $1 and ${name} expand captured groups
+	sleep 500ms             pause`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		script, err := core.ParseScript(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Error in %s: %v\n", args[0], err)
+			os.Exit(2)
+		}
+
+		var trace io.Writer
+		if verbose {
+			trace = os.Stdout
+		}
+
+		client := core.NewClient(args[1], expectPort, false, expectTimeout)
+		if err := client.RunScript(script, trace); err != nil {
+			fmt.Printf("FAIL: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("PASS")
+	},
+}
+
+func init() {
+	expectCmd.Flags().IntVarP(&expectPort, "port", "p", 8080, "Port number")
+	expectCmd.Flags().IntVarP(&expectTimeout, "timeout", "t", 5, "Connection timeout in seconds")
+	rootCmd.AddCommand(expectCmd)
+}
@@ -3,12 +3,14 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/prem0x01/ncCmdExe/internal/core"
 	"github.com/prem0x01/ncCmdExe/internal/scanner"
 	"github.com/prem0x01/ncCmdExe/internal/ui"
+	"github.com/prem0x01/ncCmdExe/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +28,41 @@ var (
 	verbose   bool
 	timeout   int
 	keepAlive bool
+	forward   string
+	compress  bool
+	secret    string
+	keyFile   string
+	peerKey   string
+	crlf      bool
+	lfOnly    bool
+	interval  time.Duration
+	telnet    bool
+	zeroIO    bool
+	rate      string
+	latency   time.Duration
+	jitter    time.Duration
+	dropRate  float64
+	udpIdle   time.Duration
+	topPorts  int
+	exclPorts string
+
+	targetFile  string
+	exclHosts   []string
+	exclFile    string
+	randomHosts bool
+	synScan     bool
+	timingName  string
+	maxRate     float64
+	maxHostRate float64
+	maxRetries  int
+	scanDelay   time.Duration
+	maxDelay    time.Duration
+	noPing      bool
+	pingPorts   []int
+	pingTypes   []string
+
+	versionIntensity int
+	serviceProbes    string
 )
 
 var rootCmd = &cobra.Command{
@@ -50,17 +87,29 @@ var rootCmd = &cobra.Command{
 		[*] Command Execution & Shell access
 		[*] Port & Service sacnning with version detection
 	`,
+	Args: cobra.ArbitraryArgs,
+
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if zeroIO {
+			os.Exit(runZeroIO(args))
+		}
 		if !listen && !scan && execute == "" && len(args) == 0 {
 			cmd.Help()
 			return
 		}
-		if !listen && !scan && execute == "" && len(args) == 1 {
+		if !listen && !scan && !clientOptionsSet() && len(args) == 1 {
 			startUIWithConnect(args[0])
 			return
 		}
 
+		if topPorts > 0 && !cmd.Flags().Changed("ports") {
+			// --top-ports alone picks from every port
+			scanPorts = ""
+		}
 		handleActions(args)
 	},
 }
@@ -73,18 +122,79 @@ func init() {
 	rootCmd.Flags().StringVarP(&execute, "execute", "e", "", "Execute command")
 	rootCmd.Flags().BoolVarP(&shell, "shell", "s", false, "Enable shell mode")
 	rootCmd.Flags().BoolVarP(&scan, "scan", "S", false, "Enable port scanning")
-	rootCmd.Flags().StringVar(&scanPorts, "ports", "1-1000", "Ports to scan (e.g., 80,443 or 1-1000)")
-	rootCmd.Flags().StringVar(&scanRange, "range", "", "IP range to scan")
+	rootCmd.Flags().StringVar(&scanPorts, "ports", "1-1000", "Ports to scan, nmap style (e.g. 22,80,1000-2000,U:53,T:443, - for all, http,ssh)")
+	rootCmd.Flags().IntVar(&topPorts, "top-ports", 0, "Scan the N most common ports (among --ports if given)")
+	rootCmd.Flags().StringVar(&exclPorts, "exclude-ports", "", "Ports to leave out of the scan")
+	rootCmd.Flags().StringVarP(&timingName, "timing", "T", "3", "Scan timing template 0-5 or paranoid, sneaky, polite, normal, aggressive, insane")
+	rootCmd.Flags().Float64Var(&maxRate, "max-rate", 0, "Send at most this many probes per second across the scan")
+	rootCmd.Flags().Float64Var(&maxHostRate, "max-host-rate", 0, "Send at most this many probes per second to each host")
+	rootCmd.Flags().IntVar(&maxRetries, "max-retries", -1, "Retransmit a timed-out probe at most this many times, -1 for the --timing default")
+	rootCmd.Flags().DurationVar(&scanDelay, "scan-delay", 0, "Wait at least this long between probes to a host (default from --timing)")
+	rootCmd.Flags().DurationVar(&maxDelay, "max-scan-delay", 0, "Cap how far the scan delay grows when probes are lost (default from --timing)")
+	rootCmd.Flags().BoolVar(&noPing, "Pn", false, "Skip host discovery and scan every target as if up")
+	rootCmd.Flags().IntSliceVar(&pingPorts, "ping-ports", []int{80, 443}, "TCP ports probed to find hosts, alongside ICMP and ARP")
+	rootCmd.Flags().StringSliceVar(&pingTypes, "ping-types", []string{"syn"}, "TCP pings sent to --ping-ports: syn, ack or both (ack needs root)")
+	rootCmd.Flags().BoolVar(&synScan, "syn", false, "SYN (half-open) scan; needs root, otherwise falls back to a connect scan")
+	rootCmd.Flags().StringVar(&scanRange, "range", "", "Targets to scan: IPs, CIDRs, octet ranges (10.0.1-3.1-254), start-end ranges or hostnames")
+	rootCmd.Flags().StringVar(&targetFile, "iL", "", "Read scan targets from a file, - for stdin")
+	rootCmd.Flags().StringSliceVar(&exclHosts, "exclude", nil, "Targets to leave out of the scan")
+	rootCmd.Flags().StringVar(&exclFile, "excludefile", "", "Read targets to leave out of the scan from a file")
+	rootCmd.Flags().BoolVar(&randomHosts, "randomize-hosts", false, "Scan targets in random order")
 	rootCmd.Flags().BoolVarP(&version, "version-scan", "v", false, "Enable version detection")
-	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "Verbose output")
+	rootCmd.Flags().IntVar(&versionIntensity, "version-intensity", 7, "Version detection intensity from 0 (only probes registered for the port) to 9 (every probe)")
+	rootCmd.Flags().StringVar(&serviceProbes, "service-probes", "", "Add probes and matches from a file in nmap-service-probes format")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Verbose output")
 	rootCmd.Flags().IntVarP(&timeout, "timeout", "t", 5, "Connection timeout in seconds")
-	rootCmd.Flags().BoolVarP(&keepAlive, "keep-alive", "k", false, "Keep connection alive")
+	rootCmd.Flags().BoolVarP(&keepAlive, "keep-alive", "k", false, "Keep connection alive (client: reconnect when it drops)")
+	rootCmd.Flags().BoolVar(&compress, "compress", false, "Compress the stream (both ends must enable it)")
+	rootCmd.Flags().StringVar(&secret, "secret", "", "Encrypt the connection with this passphrase (or set NCCMDEXE_SECRET)")
+	rootCmd.Flags().StringVar(&keyFile, "key", "", "Static X25519 private key file for encryption")
+	rootCmd.Flags().StringVar(&peerKey, "peer-key", "", "Expected peer public key file for encryption")
+	rootCmd.Flags().BoolVarP(&crlf, "crlf", "C", false, "Send CRLF as line ending")
+	rootCmd.Flags().BoolVar(&lfOnly, "lf", false, "Convert received CRLF line endings to LF")
+	rootCmd.Flags().DurationVarP(&interval, "interval", "i", 0, "Delay between sent lines (e.g. 500ms)")
+	rootCmd.Flags().BoolVar(&telnet, "telnet", false, "Answer telnet option negotiation and strip IAC sequences")
+	rootCmd.Flags().BoolVarP(&zeroIO, "zero", "z", false, "Only check that targets accept connections (host port[-range]...)")
+	rootCmd.Flags().StringVar(&rate, "rate", "", "Limit bandwidth in bytes/sec in each direction (e.g. 64k, 1M)")
+	rootCmd.Flags().DurationVar(&latency, "latency", 0, "Add one-way latency to the connection (e.g. 100ms)")
+	rootCmd.Flags().DurationVar(&jitter, "jitter", 0, "Randomly vary latency by up to this much")
+	rootCmd.Flags().Float64Var(&dropRate, "drop", 0, "Drop this percentage of packets (delayed as a retransmit over TCP)")
+	rootCmd.Flags().DurationVar(&udpIdle, "udp-idle", 2*time.Minute, "Close a UDP peer's session after this long without traffic (listen mode)")
+	rootCmd.Flags().StringVar(&forward, "forward", "", "Forward incoming connections to host:port (listen mode)")
+}
+
+// nmapFlags are nmap's single-dash long options, which cobra would
+// otherwise read as a cluster of shorthands.
+var nmapFlags = map[string]string{
+	"-Pn": "--Pn",
+	"-iL": "--iL",
 }
 
 func Execute() error {
+	rootCmd.SetArgs(nmapArgs(os.Args[1:]))
 	return rootCmd.Execute()
 }
 
+// nmapArgs rewrites nmapFlags to their double-dash form, up to a "--".
+func nmapArgs(args []string) []string {
+	rewritten := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			copy(rewritten[i:], args[i:])
+			break
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if long, ok := nmapFlags[name]; ok {
+			arg = long
+			if hasValue {
+				arg += "=" + value
+			}
+		}
+		rewritten[i] = arg
+	}
+	return rewritten
+}
+
 func startUIWithConnect(hostPort string) {
 	m := ui.NewModel()
 	m.StateToConnect(hostPort)
@@ -106,23 +216,197 @@ func startUIWithConnect(hostPort string) {
 		}
 	}
 */
+// clientOptionsSet reports whether a flag only the CLI client honours is set,
+// in which case a single host argument connects directly instead of
+// opening the TUI.
+func clientOptionsSet() bool {
+	return execute != "" || shell || compress || telnet || encrypted() ||
+		lineOptions() != (core.LineOptions{}) || shapeOptions().Enabled()
+}
+
+func encrypted() bool {
+	if secret == "" {
+		secret = os.Getenv("NCCMDEXE_SECRET")
+	}
+	return secret != "" || keyFile != ""
+}
+
+func secureConfig() *core.SecureConfig {
+	if !encrypted() {
+		return nil
+	}
+	config, err := core.NewSecureConfig(secret, keyFile, peerKey)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+func lineOptions() core.LineOptions {
+	return core.LineOptions{
+		CRLF:  crlf,
+		LF:    lfOnly,
+		Delay: interval,
+	}
+}
+
+func shapeOptions() core.ShapeOptions {
+	var bytesPerSec int64
+	if rate != "" {
+		var err error
+		bytesPerSec, err = utils.ParseByteSize(rate)
+		if err != nil {
+			fmt.Printf("Error: --rate: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if dropRate < 0 || dropRate > 100 {
+		fmt.Println("Error: --drop must be between 0 and 100")
+		os.Exit(1)
+	}
+
+	return core.ShapeOptions{
+		Rate:    bytesPerSec,
+		Latency: latency,
+		Jitter:  jitter,
+		Drop:    dropRate / 100,
+	}
+}
+
 func handleActions(args []string) {
 	if listen {
-		server := core.NewServer(port, udp, execute, shell)
+		network := "tcp"
+		if udp {
+			network = "udp"
+		}
+		server := core.NewServerWithConfig(core.ServerConfig{
+			Network:  network,
+			Address:  fmt.Sprintf(":%d", port),
+			Execute:  execute,
+			Shell:    shell,
+			Forward:  forward,
+			Compress: compress,
+			UDPIdle:  udpIdle,
+			Secure:   secureConfig(),
+			Lines:    lineOptions(),
+			Shape:    shapeOptions(),
+		})
 		server.Start()
 	} else if scan {
-		scanner := scanner.New(scanner.ScannerConfig{
-			Timeout: time.Second * 5,
-			Verbose: true,
-			Version: true,
+		timing, err := scanner.ParseTiming(timingName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, pingType := range pingTypes {
+			if pingType != "syn" && pingType != "ack" {
+				fmt.Printf("Error: unknown ping type %q, want syn or ack\n", pingType)
+				os.Exit(1)
+			}
+		}
+		if versionIntensity < 0 || versionIntensity > 9 {
+			fmt.Println("Error: --version-intensity must be between 0 and 9")
+			os.Exit(1)
+		}
+		if versionIntensity == 0 {
+			versionIntensity = scanner.VersionIntensityZero
+		}
+		if maxRetries >= 0 {
+			timing.MaxRetries = maxRetries
+		}
+		if scanDelay > 0 {
+			timing.ScanDelay = scanDelay
+		}
+		if maxDelay > 0 {
+			timing.MaxScanDelay = maxDelay
+		}
+
+		scanType := scanner.TCPScan
+		if udp {
+			scanType = scanner.UDPScan
+		} else if synScan {
+			scanType = scanner.SYNScan
+		}
+		scanner, err := scanner.New(scanner.ScannerConfig{
+			Timeout:           time.Second * 5,
+			Verbose:           true,
+			Version:           version,
+			ScanType:          scanType,
+			Timing:            timing,
+			SKipHostDomain:    noPing,
+			PingPorts:         pingPorts,
+			PingTypes:         pingTypes,
+			RateLimit:         rateInterval(maxRate),
+			HostRateLimit:     rateInterval(maxHostRate),
+			TopPorts:          topPorts,
+			ExcludePorts:      exclPorts,
+			VersionIntensity:  versionIntensity,
+			ServiceProbesFile: serviceProbes,
 		})
-		if scanRange != "" {
-			scanner.ScanRange(scanRange, scanPorts)
-		} else if len(args) > 0 {
-			scanner.ScanHost(args[0], scanPorts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(args) == 1 && scanRange == "" && targetFile == "" && len(exclHosts) == 0 && exclFile == "" && !isMultiTarget(args[0]) {
+			_, err = scanner.ScanHost(args[0], scanPorts)
+		} else {
+			err = scanTargets(scanner, args)
+		}
+		scanner.Close()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 	} else if len(args) > 0 {
-		client := core.NewClient(args[0], port, udp, timeout)
+		client := core.NewClientWithConfig(core.ClientConfig{
+			Host:      args[0],
+			Port:      port,
+			UDP:       udp,
+			Timeout:   timeout,
+			Compress:  compress,
+			Secure:    secureConfig(),
+			Execute:   execute,
+			Shell:     shell,
+			Reconnect: keepAlive,
+			Lines:     lineOptions(),
+			Telnet:    telnet,
+			Shape:     shapeOptions(),
+		})
 		client.Connect()
 	}
 }
+
+func scanTargets(s *scanner.Scanner, args []string) error {
+	specs := args
+	if scanRange != "" {
+		specs = append(specs, scanRange)
+	}
+	targets, err := scanner.ParseTargets(scanner.TargetSpec{
+		Targets:     specs,
+		InputFile:   targetFile,
+		Exclude:     exclHosts,
+		ExcludeFile: exclFile,
+		Random:      randomHosts,
+	})
+	if err != nil {
+		return err
+	}
+	return s.ScanTargetsFunc(targets, scanPorts, nil)
+}
+
+// isMultiTarget reports whether target names more than one host, such as
+// a CIDR or an address range.
+func isMultiTarget(target string) bool {
+	return strings.ContainsAny(target, "/-*, ")
+}
+
+// rateInterval turns a per-second rate into the gap between events, 0 for
+// no limit.
+func rateInterval(perSecond float64) time.Duration {
+	if perSecond <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / perSecond)
+}
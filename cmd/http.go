@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	httpMethod   string
+	httpHeaders  []string
+	httpData     string
+	httpHost     string
+	httpTLS      bool
+	httpInsecure bool
+	httpRaw      string
+	httpNoBody   bool
+	httpTimeout  int
+)
+
+var httpCmd = &cobra.Command{
+	Use:   "http <url|host[:port]> [path...]",
+	Short: "Send HTTP/1.1 requests and show the response with timings",
+	Long: `Send one or more HTTP/1.1 requests over a single connection and print
+each response along with a DNS/connect/TLS/first-byte/total breakdown.
+
+Extra paths are pipelined on the same connection. With --raw the request
+is read from a file and rendered as a Go template with .Host, .Port and
+.Path, so arbitrary (even malformed) requests can be sent.
+
+Examples:
+  ncCmdExe http https://example.com/
+  ncCmdExe http 10.0.0.5:8080 /health /metrics
+  ncCmdExe http -X POST -H "Content-Type: application/json" -d '{"a":1}' api.local/items
+  ncCmdExe http --raw smuggle.txt 10.0.0.5:80`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		host, port, path, useTLS, err := parseHTTPTarget(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		useTLS = useTLS || httpTLS
+		if port == 0 {
+			port = 80
+			if useTLS {
+				port = 443
+			}
+		}
+
+		paths := []string{path}
+		if len(args) > 1 {
+			paths = args[1:]
+		}
+
+		hostHeader := httpHost
+		if hostHeader == "" {
+			hostHeader = host
+			if (useTLS && port != 443) || (!useTLS && port != 80) {
+				hostHeader = net.JoinHostPort(host, strconv.Itoa(port))
+			}
+		}
+
+		body, err := httpBody()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		method := strings.ToUpper(httpMethod)
+		if method == "" {
+			method = "GET"
+			if len(body) > 0 {
+				method = "POST"
+			}
+		}
+
+		var raw []byte
+		if httpRaw != "" {
+			raw, err = os.ReadFile(httpRaw)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		var requests [][]byte
+		var methods []string
+		for _, p := range paths {
+			if httpRaw != "" {
+				request, err := core.RenderRawRequest(string(raw), map[string]any{
+					"Host": hostHeader,
+					"Port": port,
+					"Path": p,
+				})
+				if err != nil {
+					fmt.Printf("Error in %s: %v\n", httpRaw, err)
+					os.Exit(1)
+				}
+				requests = append(requests, request)
+				rawMethod, _, _ := strings.Cut(string(request), " ")
+				methods = append(methods, rawMethod)
+				continue
+			}
+
+			requests = append(requests, core.HTTPRequest{
+				Method:  method,
+				Path:    p,
+				Host:    hostHeader,
+				Headers: httpHeaders,
+				Body:    body,
+			}.Bytes())
+			methods = append(methods, method)
+		}
+
+		// SNI and verification use the --host name, without its port
+		serverName := httpHost
+		if h, _, err := net.SplitHostPort(httpHost); err == nil {
+			serverName = h
+		}
+		serverName = strings.TrimSuffix(strings.TrimPrefix(serverName, "["), "]")
+
+		client := core.NewClient(host, port, false, httpTimeout)
+		result, err := client.HTTP(requests, methods, core.HTTPOptions{
+			TLS:        useTLS,
+			Insecure:   httpInsecure,
+			ServerName: serverName,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		core.PrintHTTPResult(os.Stdout, result, !httpNoBody)
+		if len(result.Exchanges) < len(requests) {
+			fmt.Printf("Warning: only %d of %d responses received\n", len(result.Exchanges), len(requests))
+			os.Exit(1)
+		}
+	},
+}
+
+// parseHTTPTarget accepts a full URL or a bare host[:port][/path].
+func parseHTTPTarget(target string) (string, int, string, bool, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	} else if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return "", 0, "", false, fmt.Errorf("unsupported scheme in %q", target)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", 0, "", false, err
+	}
+	if u.Hostname() == "" {
+		return "", 0, "", false, fmt.Errorf("missing host in %q", target)
+	}
+
+	port := 0
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, "", false, fmt.Errorf("invalid port %q", p)
+		}
+	}
+
+	path := u.RequestURI()
+	return u.Hostname(), port, path, u.Scheme == "https", nil
+}
+
+func httpBody() ([]byte, error) {
+	if strings.HasPrefix(httpData, "@") {
+		return os.ReadFile(httpData[1:])
+	}
+	return []byte(httpData), nil
+}
+
+func init() {
+	httpCmd.Flags().StringVarP(&httpMethod, "method", "X", "", "Request method (default GET, or POST with -d)")
+	httpCmd.Flags().StringArrayVarP(&httpHeaders, "header", "H", nil, "Extra header, e.g. \"Accept: */*\" (repeatable)")
+	httpCmd.Flags().StringVarP(&httpData, "data", "d", "", "Request body, or @file to read it from a file")
+	httpCmd.Flags().StringVar(&httpHost, "host", "", "Override the Host header and TLS server name")
+	httpCmd.Flags().BoolVar(&httpTLS, "tls", false, "Use TLS (implied by https://)")
+	httpCmd.Flags().BoolVar(&httpInsecure, "insecure", false, "Skip TLS certificate verification")
+	httpCmd.Flags().StringVar(&httpRaw, "raw", "", "Send the raw request template in this file")
+	httpCmd.Flags().BoolVar(&httpNoBody, "no-body", false, "Do not print response bodies")
+	httpCmd.Flags().IntVarP(&httpTimeout, "timeout", "t", 10, "Connection and read timeout in seconds")
+	rootCmd.AddCommand(httpCmd)
+}
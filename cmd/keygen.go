@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen <name>",
+	Short: "Generate a static key pair for encrypted connections",
+	Long: `Generate an X25519 key pair as <name>.key and <name>.pub.
+
+Give each side its own .key with --key and the other side's .pub with
+--peer-key to authenticate both ends without certificates.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := core.GenerateKeyPair(args[0]); err != nil {
+			fmt.Printf("Error generating keys: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s.key and %s.pub\n", args[0], args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/logging"
+	"github.com/prem0x01/ncCmdExe/pkg/utils"
+)
+
+var (
+	quiet         bool
+	logFormat     string
+	logFile       string
+	logMaxSize    string
+	logMaxBackups int
+)
+
+// newLogger builds a logger from the global logging flags, writing to
+// file when it is not empty.
+func newLogger(file string) (*slog.Logger, io.Closer, error) {
+	maxSize, err := utils.ParseByteSize(logMaxSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--log-max-size: %w", err)
+	}
+
+	return logging.New(logging.Options{
+		Level:      logging.Level(verbose, quiet),
+		Format:     logFormat,
+		File:       file,
+		MaxSize:    maxSize,
+		MaxBackups: logMaxBackups,
+	})
+}
+
+func setupLogging() {
+	logger, _, err := newLogger(logFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.BoolVarP(&quiet, "quiet", "q", false, "Only log warnings and errors")
+	flags.StringVar(&logFormat, "log-format", "text", "Log format: text, json or logfmt")
+	flags.StringVar(&logFile, "log-file", "", "Write logs to this file instead of stderr")
+	flags.StringVar(&logMaxSize, "log-max-size", "10M", "Rotate the log file after this size")
+	flags.IntVar(&logMaxBackups, "log-max-backups", 3, "Number of rotated log files to keep")
+}
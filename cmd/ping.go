@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pingPort     int
+	pingCount    int
+	pingInterval time.Duration
+	pingTimeout  int
+	pingTLS      bool
+	pingInsecure bool
+	pingSNI      string
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping host[:port]",
+	Short: "Measure TCP (and TLS) handshake latency",
+	Long: `Repeatedly time the TCP handshake to a port, and with --tls the TLS
+handshake on top of it. Runs until interrupted unless -c is given, then
+prints min/avg/max/stddev, loss and a latency histogram; -q prints only
+that summary. Exits 1 if no probe succeeded.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := args[0]
+		host, portNum := target, pingPort
+		if h, p, err := net.SplitHostPort(target); err == nil {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 || n > 65535 {
+				fmt.Printf("Error: invalid port in %q\n", target)
+				os.Exit(1)
+			}
+			host, portNum = h, n
+		}
+		target = net.JoinHostPort(host, strconv.Itoa(portNum))
+
+		client, err := core.NewClient(host, portNum, false, pingTimeout).Resolve()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts := core.PingOptions{TLS: pingTLS, Insecure: pingInsecure, ServerName: pingSNI}
+		if opts.ServerName == "" {
+			opts.ServerName = host
+		}
+		stats := &core.PingStats{}
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	probes:
+		for seq := 1; pingCount == 0 || seq <= pingCount; seq++ {
+			sample := client.Ping(seq, opts)
+			stats.Add(sample)
+
+			if !quiet {
+				switch {
+				case sample.Err != nil:
+					fmt.Printf("%s: seq=%d failed: %v\n", target, seq, sample.Err)
+				case pingTLS:
+					fmt.Printf("Connected to %s: seq=%d time=%s (tcp=%s tls=%s)\n", sample.Addr, seq,
+						core.FormatMillis(sample.Total()), core.FormatMillis(sample.Connect), core.FormatMillis(sample.TLS))
+				default:
+					fmt.Printf("Connected to %s: seq=%d time=%s\n", sample.Addr, seq, core.FormatMillis(sample.Connect))
+				}
+			}
+
+			if pingCount != 0 && seq == pingCount {
+				break
+			}
+			select {
+			case <-interrupt:
+				break probes
+			case <-time.After(pingInterval):
+			}
+		}
+
+		fmt.Println()
+		core.PrintPingStats(os.Stdout, target, stats)
+		if stats.Received == 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	pingCmd.Flags().IntVarP(&pingPort, "port", "p", 80, "Port used when the target has none")
+	pingCmd.Flags().IntVarP(&pingCount, "count", "c", 0, "Stop after this many probes (0 runs until interrupted)")
+	pingCmd.Flags().DurationVarP(&pingInterval, "interval", "i", time.Second, "Delay between probes")
+	pingCmd.Flags().IntVarP(&pingTimeout, "timeout", "t", 5, "Probe timeout in seconds")
+	pingCmd.Flags().BoolVar(&pingTLS, "tls", false, "Also time a TLS handshake")
+	pingCmd.Flags().BoolVar(&pingInsecure, "insecure", false, "Skip TLS certificate verification")
+	pingCmd.Flags().StringVar(&pingSNI, "sni", "", "TLS server name (defaults to the host)")
+	rootCmd.AddCommand(pingCmd)
+}
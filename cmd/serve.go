@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var serveConfig string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run many listeners at once from a services config file",
+	Long: `Run many listeners at once from a YAML services file.
+
+Example services.yaml:
+
+	log_file: /var/log/ncCmdExe.log
+	services:
+	  - name: echo
+	    network: tcp
+	    address: ":7000"
+	    execute: cat
+	  - name: admin
+	    network: tcp
+	    address: ":7443"
+	    shell: true
+	    tls:
+	      cert: server.crt
+	      key: server.key
+	      client_ca: clients.pem
+	    allow: ["10.0.0.0/8"]
+	  - name: web-proxy
+	    network: unix
+	    address: /tmp/web.sock
+	    forward: "127.0.0.1:80"
+	    shape:
+	      rate: 65536
+	      latency: 150ms
+	      jitter: 30ms
+	      drop: 1
+	  - name: syslog
+	    network: udp
+	    address: ":5514"
+	    deny: ["192.168.1.13"]
+
+Send SIGHUP to reload the file: changed services are restarted, removed
+ones are stopped and new ones are started.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := core.LoadServices(serveConfig)
+		if err != nil {
+			fmt.Printf("Error loading services: %v\n", err)
+			os.Exit(1)
+		}
+
+		logger := slog.Default()
+		if file.LogFile != "" && logFile == "" {
+			var closer io.Closer
+			logger, closer, err = newLogger(file.LogFile)
+			if err != nil {
+				fmt.Printf("Error opening log file: %v\n", err)
+				os.Exit(1)
+			}
+			defer closer.Close()
+		}
+
+		host := core.NewServiceHost(serveConfig, logger)
+		if err := host.Run(); err != nil {
+			fmt.Printf("Error starting services: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&serveConfig, "config", "c", "services.yaml", "Services config file")
+	rootCmd.AddCommand(serveCmd)
+}
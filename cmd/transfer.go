@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	transferListen  bool
+	transferPort    int
+	transferTimeout int
+	recvDir         string
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send <path> [host]",
+	Short: "Send a file or directory with SHA-256 verification",
+	Long: `Send a file or directory to a peer running "recv".
+
+Interrupted transfers resume from where the receiver stopped. Directories
+are tarred on the fly. Use -l to wait for the receiver to connect instead
+of dialing it.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if transferListen {
+			server := core.NewServerWithConfig(core.ServerConfig{
+				Address:  fmt.Sprintf(":%d", transferPort),
+				SendPath: path,
+				Once:     true,
+			})
+			if err := server.ListenAndServe(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(args) < 2 {
+			fmt.Println("Error: host is required unless --listen is set")
+			os.Exit(1)
+		}
+		client := core.NewClient(args[1], transferPort, false, transferTimeout)
+		if err := client.SendFile(path); err != nil {
+			fmt.Printf("Transfer failed: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var recvCmd = &cobra.Command{
+	Use:   "recv [host]",
+	Short: "Receive a file or directory sent with \"send\"",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if info, err := os.Stat(recvDir); err != nil || !info.IsDir() {
+			fmt.Printf("Error: %s is not a directory\n", recvDir)
+			os.Exit(1)
+		}
+
+		if transferListen {
+			server := core.NewServerWithConfig(core.ServerConfig{
+				Address:    fmt.Sprintf(":%d", transferPort),
+				ReceiveDir: recvDir,
+				Once:       true,
+			})
+			if err := server.ListenAndServe(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(args) < 1 {
+			fmt.Println("Error: host is required unless --listen is set")
+			os.Exit(1)
+		}
+		client := core.NewClient(args[0], transferPort, false, transferTimeout)
+		path, err := client.ReceiveFile(recvDir)
+		if err != nil {
+			fmt.Printf("Transfer failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %s\n", path)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{sendCmd, recvCmd} {
+		c.Flags().BoolVarP(&transferListen, "listen", "l", false, "Wait for the peer to connect")
+		c.Flags().IntVarP(&transferPort, "port", "p", 8080, "Port number")
+		c.Flags().IntVarP(&transferTimeout, "timeout", "t", 5, "Connection timeout in seconds")
+		rootCmd.AddCommand(c)
+	}
+	recvCmd.Flags().StringVarP(&recvDir, "output", "o", ".", "Directory to save received files")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitTimeout     time.Duration
+	waitAny         bool
+	waitInterval    time.Duration
+	waitMaxInterval time.Duration
+	waitDialTimeout time.Duration
+	waitBanner      string
+	waitHTTPStatus  int
+	waitHTTPPath    string
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait host:port [host:port...]",
+	Short: "Block until targets accept connections",
+	Long: `Poll targets with exponential backoff until all of them (or any, with
+--any) accept connections. Optionally require a banner matching a regex or
+an HTTP status code. Exits 1 if the timeout expires first. --verbose
+reports every failed attempt and -q prints nothing, leaving only the
+exit status.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := core.WaitConfig{
+			Any:         waitAny,
+			Interval:    waitInterval,
+			MaxInterval: waitMaxInterval,
+			DialTimeout: waitDialTimeout,
+			HTTPStatus:  waitHTTPStatus,
+			HTTPPath:    waitHTTPPath,
+		}
+		if waitBanner != "" {
+			pattern, err := regexp.Compile(waitBanner)
+			if err != nil {
+				fmt.Printf("Error: invalid banner regex: %v\n", err)
+				os.Exit(2)
+			}
+			config.Banner = pattern
+		}
+
+		for _, target := range args {
+			if _, _, err := core.ParseWaitTarget(target); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(2)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+		defer cancel()
+
+		err := core.WaitFor(ctx, args, config, func(e core.WaitEvent) {
+			if quiet {
+				return
+			}
+			if e.Ready {
+				fmt.Printf("%s is ready after %s\n", e.Target, e.Elapsed.Round(time.Millisecond))
+			} else if verbose {
+				fmt.Printf("%s not ready (attempt %d): %v\n", e.Target, e.Attempt, e.Err)
+			}
+		})
+		if err != nil {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", 60*time.Second, "Give up after this long")
+	waitCmd.Flags().BoolVar(&waitAny, "any", false, "Succeed as soon as any target is ready")
+	waitCmd.Flags().DurationVar(&waitInterval, "interval", 500*time.Millisecond, "Initial delay between attempts")
+	waitCmd.Flags().DurationVar(&waitMaxInterval, "max-interval", 5*time.Second, "Maximum delay between attempts")
+	waitCmd.Flags().DurationVar(&waitDialTimeout, "dial-timeout", 5*time.Second, "Timeout for each attempt")
+	waitCmd.Flags().StringVar(&waitBanner, "banner", "", "Require the service banner to match this regex")
+	waitCmd.Flags().IntVar(&waitHTTPStatus, "http-status", 0, "Require this HTTP status code")
+	waitCmd.Flags().StringVar(&waitHTTPPath, "http-path", "/", "Path requested for --http-status")
+	rootCmd.AddCommand(waitCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/prem0x01/ncCmdExe/internal/core"
+	"github.com/prem0x01/ncCmdExe/internal/scanner"
+	"github.com/prem0x01/ncCmdExe/pkg/utils"
+)
+
+type zeroTarget struct {
+	host       string
+	start, end int
+}
+
+// parseZeroTargets accepts "host port[-range]..." groups as well as
+// "host:port[-range]". A host without ports uses the --port flag.
+func parseZeroTargets(args []string, defaultPort int) ([]zeroTarget, error) {
+	var targets []zeroTarget
+	host := ""
+	hostHasPorts := false
+
+	flush := func() {
+		if host != "" && !hostHasPorts {
+			targets = append(targets, zeroTarget{host: host, start: defaultPort, end: defaultPort})
+		}
+	}
+
+	for _, arg := range args {
+		if start, end, err := utils.ParsePortRange(arg); err == nil {
+			if host == "" {
+				return nil, fmt.Errorf("port %q given before any host", arg)
+			}
+			targets = append(targets, zeroTarget{host: host, start: start, end: end})
+			hostHasPorts = true
+			continue
+		}
+
+		flush()
+		if h, p, err := net.SplitHostPort(arg); err == nil {
+			start, end, err := utils.ParsePortRange(p)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, zeroTarget{host: h, start: start, end: end})
+			host, hostHasPorts = h, true
+			continue
+		}
+		host, hostHasPorts = arg, false
+	}
+	flush()
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets given")
+	}
+	return targets, nil
+}
+
+// zeroWorkers bounds how many ports are checked at once. A UDP check only
+// succeeds by waiting out the timeout, so checking ports one at a time
+// would make ranges crawl.
+const zeroWorkers = 64
+
+// runZeroIO checks every target and returns the process exit status:
+// 0 if all succeeded, 1 if any failed, 2 on usage errors.
+func runZeroIO(args []string) int {
+	targets, err := parseZeroTargets(args, port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	protocol := "tcp"
+	if udp {
+		protocol = "udp"
+	}
+
+	type check struct {
+		host   string
+		port   int
+		result chan error
+	}
+	var checks []check
+	for _, target := range targets {
+		for p := target.start; p <= target.end; p++ {
+			checks = append(checks, check{host: target.host, port: p, result: make(chan error, 1)})
+		}
+	}
+
+	go func() {
+		sem := make(chan struct{}, zeroWorkers)
+		for _, c := range checks {
+			sem <- struct{}{}
+			go func(c check) {
+				defer func() { <-sem }()
+				c.result <- core.NewClient(c.host, c.port, udp, timeout).TestConnection()
+			}(c)
+		}
+	}()
+
+	status := 0
+	for _, c := range checks {
+		if err := <-c.result; err != nil {
+			fmt.Fprintf(os.Stderr, "Connection to %s %d port [%s/%s] failed: %v\n",
+				c.host, c.port, protocol, scanner.ServiceName(c.port), err)
+			status = 1
+			continue
+		}
+		fmt.Printf("Connection to %s %d port [%s/%s] succeeded!\n",
+			c.host, c.port, protocol, scanner.ServiceName(c.port))
+	}
+	return status
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"net"
+
+	"github.com/prem0x01/ncCmdExe/pkg/utils"
+)
+
+type ACL struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func NewACL(allow, deny []string) (*ACL, error) {
+	acl := &ACL{}
+
+	for _, entry := range allow {
+		ipNet, err := utils.ParseIPNet(entry)
+		if err != nil {
+			return nil, err
+		}
+		acl.allow = append(acl.allow, ipNet)
+	}
+	for _, entry := range deny {
+		ipNet, err := utils.ParseIPNet(entry)
+		if err != nil {
+			return nil, err
+		}
+		acl.deny = append(acl.deny, ipNet)
+	}
+
+	return acl, nil
+}
+
+// Permits applies deny rules first, then allow rules. An empty allow list
+// admits everyone not denied. Addresses without an IP (Unix sockets) are
+// always permitted.
+func (a *ACL) Permits(addr net.Addr) bool {
+	if a == nil {
+		return true
+	}
+
+	ip := utils.AddrIP(addr)
+	if ip == nil {
+		return true
+	}
+
+	for _, ipNet := range a.deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, ipNet := range a.allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
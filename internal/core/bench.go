@@ -0,0 +1,547 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// BenchConfig describes one benchmark run. Rate is per stream in bytes per
+// second; 0 sends as fast as possible, which is only sensible over TCP.
+type BenchConfig struct {
+	Duration   time.Duration
+	Streams    int
+	BufferSize int
+	Reverse    bool
+	Rate       int64
+}
+
+type BenchStream struct {
+	ID            int     `json:"id"`
+	BytesSent     int64   `json:"bytes_sent,omitempty"`
+	BytesReceived int64   `json:"bytes_received"`
+	Seconds       float64 `json:"seconds"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Packets       int64   `json:"packets,omitempty"`
+	Lost          int64   `json:"lost,omitempty"`
+	LossPercent   float64 `json:"loss_percent,omitempty"`
+	OutOfOrder    int64   `json:"out_of_order,omitempty"`
+	JitterMs      float64 `json:"jitter_ms,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+type BenchResult struct {
+	Protocol string        `json:"protocol"`
+	Reverse  bool          `json:"reverse"`
+	Streams  []BenchStream `json:"streams"`
+	Sum      BenchStream   `json:"sum"`
+}
+
+// benchHello is the first frame of every stream.
+type benchHello struct {
+	Magic    string        `json:"magic"`
+	Duration time.Duration `json:"duration"`
+	Buffer   int           `json:"buffer"`
+	Reverse  bool          `json:"reverse"`
+	Rate     int64         `json:"rate"`
+}
+
+// benchReport is what a receiver measured.
+type benchReport struct {
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	Packets    int64   `json:"packets,omitempty"`
+	Lost       int64   `json:"lost,omitempty"`
+	OutOfOrder int64   `json:"out_of_order,omitempty"`
+	JitterMs   float64 `json:"jitter_ms,omitempty"`
+}
+
+const (
+	benchMagic = "NCB1"
+
+	// UDP datagrams carry a sequence number and send time; a sequence of
+	// benchFinSeq marks the end and carries the number of packets sent.
+	benchHeaderSize = 16
+	benchFinSeq     = math.MaxUint64
+	maxDatagramSize = 65507
+)
+
+// Bench runs a throughput test against a "bench" server.
+func (c *Client) Bench(config BenchConfig) (*BenchResult, error) {
+	if config.Streams < 1 {
+		config.Streams = 1
+	}
+	if config.BufferSize < benchHeaderSize {
+		return nil, fmt.Errorf("buffer size must be at least %d bytes", benchHeaderSize)
+	}
+	if c.udp && config.BufferSize > maxDatagramSize {
+		return nil, fmt.Errorf("UDP buffer size must be at most %d bytes", maxDatagramSize)
+	}
+
+	result := &BenchResult{
+		Protocol: c.protocol(),
+		Reverse:  config.Reverse,
+		Streams:  make([]BenchStream, config.Streams),
+	}
+
+	var wg sync.WaitGroup
+	for i := range result.Streams {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stream, err := c.benchStream(config)
+			if err != nil {
+				stream.Error = err.Error()
+			}
+			stream.ID = i + 1
+			result.Streams[i] = stream
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, s := range result.Streams {
+		if s.Error != "" {
+			failed++
+			continue
+		}
+		result.Sum.BytesSent += s.BytesSent
+		result.Sum.BytesReceived += s.BytesReceived
+		result.Sum.BitsPerSecond += s.BitsPerSecond
+		result.Sum.Packets += s.Packets
+		result.Sum.Lost += s.Lost
+		result.Sum.OutOfOrder += s.OutOfOrder
+		result.Sum.JitterMs += s.JitterMs
+		result.Sum.Seconds = max(result.Sum.Seconds, s.Seconds)
+	}
+	if ok := len(result.Streams) - failed; ok > 0 {
+		result.Sum.JitterMs /= float64(ok)
+	}
+	if total := result.Sum.Packets + result.Sum.Lost; total > 0 {
+		result.Sum.LossPercent = float64(result.Sum.Lost) * 100 / float64(total)
+	}
+	if failed == len(result.Streams) {
+		return result, errors.New(result.Streams[0].Error)
+	}
+	return result, nil
+}
+
+func (c *Client) benchStream(config BenchConfig) (BenchStream, error) {
+	var stream BenchStream
+
+	conn, err := c.Dial()
+	if err != nil {
+		return stream, err
+	}
+	defer conn.Close()
+
+	hello := benchHello{
+		Magic:    benchMagic,
+		Duration: config.Duration,
+		Buffer:   config.BufferSize,
+		Reverse:  config.Reverse,
+		Rate:     config.Rate,
+	}
+	if err := writeFrame(conn, hello); err != nil {
+		return stream, err
+	}
+
+	var report *benchReport
+	switch {
+	case !c.udp && !config.Reverse:
+		stream.BytesSent, err = sendBenchStream(conn, hello)
+		if err != nil {
+			return stream, err
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Duration(c.timeout) * time.Second))
+		report = &benchReport{}
+		if err := readFrame(conn, report); err != nil {
+			return stream, fmt.Errorf("no report from server: %w", err)
+		}
+
+	case !c.udp:
+		conn.SetReadDeadline(time.Now().Add(config.Duration + time.Duration(c.timeout)*time.Second))
+		report, err = receiveBenchStream(conn)
+		if err != nil {
+			return stream, err
+		}
+
+	case !config.Reverse:
+		var sent int64
+		stream.BytesSent, sent, err = sendDatagrams(conn, hello)
+		if err != nil {
+			return stream, err
+		}
+		report, err = finishDatagrams(conn, sent)
+		if err != nil {
+			return stream, err
+		}
+
+	default:
+		report, err = receiveDatagrams(conn, hello, time.Duration(c.timeout)*time.Second)
+		if err != nil {
+			return stream, err
+		}
+	}
+
+	stream.BytesReceived = report.Bytes
+	stream.Seconds = report.Seconds
+	if report.Seconds > 0 {
+		stream.BitsPerSecond = float64(report.Bytes) * 8 / report.Seconds
+	}
+	stream.Packets = report.Packets
+	stream.Lost = report.Lost
+	stream.OutOfOrder = report.OutOfOrder
+	stream.JitterMs = report.JitterMs
+	if total := report.Packets + report.Lost; total > 0 {
+		stream.LossPercent = float64(report.Lost) * 100 / float64(total)
+	}
+	return stream, nil
+}
+
+// benchServe answers a single stream opened by Client.Bench.
+func (s *Server) benchServe(conn net.Conn, log *slog.Logger) {
+	datagram := strings.HasPrefix(conn.LocalAddr().Network(), "udp")
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var hello benchHello
+	var err error
+	if datagram {
+		err = readPacketFrame(conn, &hello)
+	} else {
+		err = readFrame(conn, &hello)
+	}
+	if err == nil && hello.Magic != benchMagic {
+		err = errors.New("not a bench client")
+	}
+	if err == nil && (hello.Buffer < benchHeaderSize || hello.Buffer > 16<<20) {
+		err = fmt.Errorf("invalid buffer size %d", hello.Buffer)
+	}
+	if err == nil && (hello.Duration <= 0 || hello.Duration > time.Hour) {
+		err = fmt.Errorf("invalid duration %s", hello.Duration)
+	}
+	if err != nil {
+		log.Warn("bench handshake failed", "error", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	log.Info("bench started", "reverse", hello.Reverse, "duration", hello.Duration, "buffer", hello.Buffer)
+
+	var total int64
+	switch {
+	case !datagram && !hello.Reverse:
+		var report *benchReport
+		report, err = receiveBenchStream(conn)
+		if err == nil {
+			total = report.Bytes
+			err = writeFrame(conn, report)
+		}
+	case !datagram:
+		total, err = sendBenchStream(conn, hello)
+	case !hello.Reverse:
+		total, err = serveDatagrams(conn)
+	default:
+		var sent int64
+		total, sent, err = sendDatagrams(conn, hello)
+		for i := 0; i < 3 && err == nil; i++ {
+			time.Sleep(100 * time.Millisecond)
+			_, err = conn.Write(finDatagram(sent))
+		}
+	}
+
+	if err != nil {
+		log.Warn("bench failed", "error", err)
+		return
+	}
+	log.Info("bench finished", "bytes", total)
+}
+
+// pace sleeps so that sent bytes since start do not exceed rate.
+func pace(start time.Time, sent int64, rate int64) {
+	if rate <= 0 {
+		return
+	}
+	due := start.Add(time.Duration(float64(sent) / float64(rate) * float64(time.Second)))
+	time.Sleep(time.Until(due))
+}
+
+func sendBenchStream(conn net.Conn, hello benchHello) (int64, error) {
+	buffer := make([]byte, hello.Buffer)
+	start := time.Now()
+	var sent int64
+	for time.Since(start) < hello.Duration {
+		n, err := conn.Write(buffer)
+		sent += int64(n)
+		if err != nil {
+			return sent, err
+		}
+		pace(start, sent, hello.Rate)
+	}
+	return sent, nil
+}
+
+func receiveBenchStream(conn net.Conn) (*benchReport, error) {
+	buffer := make([]byte, 128*1024)
+	var start time.Time
+	var total int64
+	for {
+		n, err := conn.Read(buffer)
+		if n > 0 && start.IsZero() {
+			start = time.Now()
+		}
+		total += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &benchReport{Bytes: total}
+	if !start.IsZero() {
+		report.Seconds = time.Since(start).Seconds()
+	}
+	return report, nil
+}
+
+func sendDatagrams(conn net.Conn, hello benchHello) (int64, int64, error) {
+	buffer := make([]byte, hello.Buffer)
+	start := time.Now()
+	var sent, packets int64
+	for time.Since(start) < hello.Duration {
+		binary.BigEndian.PutUint64(buffer[0:8], uint64(packets))
+		binary.BigEndian.PutUint64(buffer[8:16], uint64(time.Now().UnixNano()))
+		n, err := conn.Write(buffer)
+		if err != nil && !isRefused(err) {
+			return sent, packets, err
+		}
+		sent += int64(n)
+		packets++
+		pace(start, sent, hello.Rate)
+	}
+	return sent, packets, nil
+}
+
+func finDatagram(packets int64) []byte {
+	fin := make([]byte, benchHeaderSize)
+	binary.BigEndian.PutUint64(fin[0:8], benchFinSeq)
+	binary.BigEndian.PutUint64(fin[8:16], uint64(packets))
+	return fin
+}
+
+// finishDatagrams sends the end marker until the server answers with its
+// report.
+func finishDatagrams(conn net.Conn, packets int64) (*benchReport, error) {
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if _, err = conn.Write(finDatagram(packets)); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		report := &benchReport{}
+		if err = readPacketFrame(conn, report); err == nil {
+			return report, nil
+		}
+	}
+	return nil, fmt.Errorf("no report from server: %w", err)
+}
+
+// datagramStats tracks loss, reordering and RFC 3550 interarrival jitter.
+type datagramStats struct {
+	bytes      int64
+	packets    int64
+	nextSeq    uint64
+	outOfOrder int64
+	first      time.Time
+	last       time.Time
+	transit    float64
+	jitter     float64
+}
+
+// add records one datagram and reports whether it was the end marker,
+// along with the packet count the sender claimed.
+func (d *datagramStats) add(packet []byte) (bool, int64) {
+	if len(packet) < benchHeaderSize {
+		return false, 0
+	}
+	seq := binary.BigEndian.Uint64(packet[0:8])
+	stamp := int64(binary.BigEndian.Uint64(packet[8:16]))
+	if seq == benchFinSeq {
+		return true, stamp
+	}
+
+	now := time.Now()
+	if d.first.IsZero() {
+		d.first = now
+	}
+	d.last = now
+	d.bytes += int64(len(packet))
+	d.packets++
+
+	if seq < d.nextSeq {
+		d.outOfOrder++
+	} else {
+		d.nextSeq = seq + 1
+	}
+
+	transit := float64(now.UnixNano() - stamp)
+	if d.packets > 1 {
+		d.jitter += (math.Abs(transit-d.transit) - d.jitter) / 16
+	}
+	d.transit = transit
+	return false, 0
+}
+
+func (d *datagramStats) report(sent int64) *benchReport {
+	if sent == 0 {
+		sent = int64(d.nextSeq)
+	}
+	report := &benchReport{
+		Bytes:      d.bytes,
+		Seconds:    d.last.Sub(d.first).Seconds(),
+		Packets:    d.packets,
+		Lost:       max(sent-d.packets, 0),
+		OutOfOrder: d.outOfOrder,
+		JitterMs:   d.jitter / float64(time.Millisecond),
+	}
+	return report
+}
+
+// serveDatagrams receives a UDP stream and answers every end marker with
+// a report until the client goes quiet.
+func serveDatagrams(conn net.Conn) (int64, error) {
+	stats := &datagramStats{}
+	buffer := make([]byte, maxDatagramSize)
+	idle := 10 * time.Second
+	finished := false
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			if finished && errors.Is(err, os.ErrDeadlineExceeded) {
+				return stats.bytes, nil
+			}
+			return stats.bytes, err
+		}
+		fin, sent := stats.add(buffer[:n])
+		if !fin {
+			continue
+		}
+		if err := writeFrame(conn, stats.report(sent)); err != nil {
+			return stats.bytes, err
+		}
+		finished = true
+		idle = 2 * time.Second
+	}
+}
+
+// receiveDatagrams measures a UDP stream sent by the server, resending the
+// hello until the first packet arrives in case it was lost.
+func receiveDatagrams(conn net.Conn, hello benchHello, timeout time.Duration) (*benchReport, error) {
+	stats := &datagramStats{}
+	buffer := make([]byte, maxDatagramSize)
+	deadline := time.Now().Add(hello.Duration + timeout)
+
+	for attempt := 0; ; {
+		wait := deadline
+		if stats.packets == 0 {
+			wait = time.Now().Add(time.Second)
+		}
+		conn.SetReadDeadline(wait)
+		n, err := conn.Read(buffer)
+		if errors.Is(err, os.ErrDeadlineExceeded) && stats.packets == 0 && attempt < 3 {
+			attempt++
+			if err := writeFrame(conn, hello); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			if stats.packets > 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+				return stats.report(0), nil
+			}
+			return nil, err
+		}
+		if fin, sent := stats.add(buffer[:n]); fin {
+			return stats.report(sent), nil
+		}
+	}
+}
+
+// readPacketFrame reads a frame that arrived as a single datagram.
+func readPacketFrame(conn net.Conn, v any) error {
+	buffer := make([]byte, maxDatagramSize)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return err
+	}
+	return readFrame(bytes.NewReader(buffer[:n]), v)
+}
+
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func formatBits(bitsPerSecond float64) string {
+	units := []string{"bit/s", "Kbit/s", "Mbit/s", "Gbit/s", "Tbit/s"}
+	i := 0
+	for bitsPerSecond >= 1000 && i < len(units)-1 {
+		bitsPerSecond /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", bitsPerSecond, units[i])
+}
+
+func PrintBenchResult(w io.Writer, result *BenchResult) {
+	udp := result.Protocol == "udp"
+	direction := "client -> server"
+	if result.Reverse {
+		direction = "server -> client"
+	}
+	fmt.Fprintln(w, clientStyle.Render(fmt.Sprintf("%s benchmark, %s", strings.ToUpper(result.Protocol), direction)))
+
+	header := fmt.Sprintf("%-6s %10s %12s %16s", "[ ID]", "Seconds", "Transfer", "Bitrate")
+	if udp {
+		header += fmt.Sprintf(" %10s %22s", "Jitter", "Lost/Total")
+	}
+	fmt.Fprintln(w, header)
+
+	line := func(id string, s BenchStream) {
+		if s.Error != "" {
+			fmt.Fprintf(w, "%-6s %s\n", id, httpErrorStyle.Render(s.Error))
+			return
+		}
+		text := fmt.Sprintf("%-6s %10.2f %12s %16s", id, s.Seconds, formatBytes(float64(s.BytesReceived)), formatBits(s.BitsPerSecond))
+		if udp {
+			text += fmt.Sprintf(" %8.3fms %22s", s.JitterMs,
+				fmt.Sprintf("%d/%d (%.2g%%)", s.Lost, s.Packets+s.Lost, s.LossPercent))
+			if s.OutOfOrder > 0 {
+				text += fmt.Sprintf("  %d out of order", s.OutOfOrder)
+			}
+		}
+		fmt.Fprintln(w, text)
+	}
+
+	for _, s := range result.Streams {
+		line(fmt.Sprintf("[%3d]", s.ID), s)
+	}
+	if len(result.Streams) > 1 {
+		line("[SUM]", result.Sum)
+	}
+}
@@ -1,10 +1,13 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -17,49 +20,288 @@ var (
 )
 
 type Client struct {
-	host    string
-	port    int
-	udp     bool
-	timeout int
+	host      string
+	port      int
+	udp       bool
+	timeout   int
+	compress  bool
+	secure    *SecureConfig
+	execute   string
+	shell     bool
+	reconnect bool
+	lines     LineOptions
+	telnet    bool
+	shape     ShapeOptions
+	logger    *slog.Logger
+}
+
+type ClientConfig struct {
+	Host      string
+	Port      int
+	UDP       bool
+	Timeout   int
+	Compress  bool
+	Secure    *SecureConfig
+	Execute   string
+	Shell     bool
+	Reconnect bool
+	Lines     LineOptions
+	Telnet    bool
+	Shape     ShapeOptions
+	Logger    *slog.Logger
 }
 
 func NewClient(host string, port int, udp bool, timeout int) *Client {
-	return &Client{
-		host:    host,
-		port:    port,
-		udp:     udp,
-		timeout: timeout,
+	return NewClientWithConfig(ClientConfig{
+		Host:    host,
+		Port:    port,
+		UDP:     udp,
+		Timeout: timeout,
+	})
+}
+
+func NewClientWithConfig(config ClientConfig) *Client {
+	if config.Logger == nil {
+		config.Logger = slog.Default()
 	}
 
+	return &Client{
+		host:      config.Host,
+		port:      config.Port,
+		udp:       config.UDP,
+		timeout:   config.Timeout,
+		compress:  config.Compress,
+		secure:    config.Secure,
+		execute:   config.Execute,
+		shell:     config.Shell,
+		reconnect: config.Reconnect,
+		lines:     config.Lines,
+		telnet:    config.Telnet,
+		shape:     config.Shape,
+		logger:    config.Logger,
+	}
 }
 
+// TestConnection checks reachability without sending any payload. Over
+// UDP an empty datagram is sent; silence counts as success because only an
+// ICMP port-unreachable proves the port closed.
 func (c *Client) TestConnection() error {
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
-	conn, err := net.DialTimeout("tcp", addr, time.Duration(c.timeout)*time.Second)
+	return c.TestConnectionContext(context.Background())
+}
+
+// TestConnectionContext is TestConnection, given up when ctx is done.
+func (c *Client) TestConnectionContext(ctx context.Context) error {
+	conn, err := c.DialContext(ctx)
 	if err != nil {
 		return err
 	}
-	conn.Close()
+	defer conn.Close()
+	if !c.udp {
+		return nil
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if _, err := conn.Write(nil); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Duration(c.timeout) * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
 	return nil
 }
 
-func (c *Client) Connect() {
-	protocol := "tcp"
+// Resolve returns a copy of c that dials the first address of its host,
+// so that repeated dials skip the lookup.
+func (c *Client) Resolve() (*Client, error) {
+	resolved := *c
+	if net.ParseIP(c.host) != nil {
+		return &resolved, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.timeout)*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, c.host)
+	if err != nil {
+		return nil, err
+	}
+	resolved.host = addrs[0].IP.String()
+	return &resolved, nil
+}
+
+func (c *Client) address() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.port))
+}
+
+func (c *Client) protocol() string {
 	if c.udp {
-		protocol = "udp"
+		return "udp"
 	}
+	return "tcp"
+}
 
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
+func (c *Client) Dial() (net.Conn, error) {
+	return c.DialContext(context.Background())
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: time.Duration(c.timeout) * time.Second}
+	return dialer.DialContext(ctx, c.protocol(), c.address())
+}
+
+// session dials the peer and applies the negotiated stream layers. The
+// countingConn counts bytes on the socket itself, as the server does.
+func (c *Client) session() (net.Conn, *countingConn, error) {
+	if c.udp && (c.compress || c.secure != nil) {
+		return nil, nil, fmt.Errorf("compression and encryption require TCP")
+	}
 
-	conn, err := net.DialTimeout(protocol, addr, time.Duration(c.timeout)*time.Second)
+	raw, err := c.Dial()
 	if err != nil {
-		fmt.Printf("Failed to connect to %s: %v\n", addr, err)
+		return nil, nil, err
+	}
+	counted := &countingConn{Conn: raw}
+	var conn net.Conn = counted
+
+	if c.secure != nil {
+		sc, err := SecureHandshake(conn, c.secure, true)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = sc
+	}
+
+	if c.compress {
+		cc, err := NegotiateCompression(conn, true)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = cc
+	}
+	return conn, counted, nil
+}
+
+func (c *Client) Connect() {
+	if c.execute != "" || c.shell {
+		c.connectBack()
+		return
+	}
+
+	log := c.logger.With("session", newSessionID(), "remote", c.address(), "protocol", c.protocol())
+
+	conn, counted, err := c.session()
+	if err != nil {
+		log.Error("failed to connect", "error", err)
 		return
 	}
 	defer conn.Close()
 
-	fmt.Println(clientStyle.Render(fmt.Sprintf("Connected to %s://%s", protocol, addr)))
+	start := time.Now()
+	log.Info("connected")
+
+	var stream net.Conn = NewShapedConn(conn, c.shape)
+	defer stream.Close()
+	if c.telnet {
+		stream = NewTelnetConn(stream, os.Getenv("TERM"))
+	}
+
+	go io.Copy(c.lines.Outbound(stream), os.Stdin)
+	inbound := c.lines.Inbound(os.Stdout)
+	io.Copy(inbound, stream)
+	flushFilter(inbound)
+
+	log.Info("disconnected",
+		"bytes_in", counted.bytesIn.Load(),
+		"bytes_out", counted.bytesOut.Load(),
+		"duration", time.Since(start))
+	if cc, ok := conn.(*CompressedConn); ok {
+		fmt.Fprintln(os.Stderr, cc.Stats())
+	}
+}
+
+// connectBack runs the configured command or shell over the connection,
+// redialing with exponential backoff when reconnect is enabled.
+func (c *Client) connectBack() {
+	const maxDelay = time.Minute
+	delay := time.Second
+
+	for {
+		log := c.logger.With("session", newSessionID(), "remote", c.address(), "protocol", c.protocol())
+
+		conn, counted, err := c.session()
+		if err != nil {
+			log.Error("failed to connect", "error", err)
+		} else {
+			delay = time.Second
+			log.Info("connected")
+
+			if c.execute != "" {
+				err = RunCommand(conn, c.execute)
+				logExit(log, "command finished", err, "command", c.execute)
+			} else {
+				err = RunShell(conn)
+				logExit(log, "shell finished", err)
+			}
+			if err != nil {
+				fmt.Fprintf(conn, "Error executing command: %v\n", err)
+			}
+			conn.Close()
+			log.Info("disconnected", "bytes_in", counted.bytesIn.Load(), "bytes_out", counted.bytesOut.Load())
+		}
+
+		if !c.reconnect {
+			return
+		}
+
+		log.Info("reconnecting", "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func (c *Client) RunScript(script *Script, trace io.Writer) error {
+	conn, _, err := c.session()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return script.Run(conn, trace)
+}
+
+func (c *Client) SendFile(path string) error {
+	if c.udp {
+		return fmt.Errorf("file transfer requires TCP")
+	}
+	conn, _, err := c.session()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return SendFile(conn, path, true)
+}
+
+func (c *Client) ReceiveFile(dir string) (string, error) {
+	if c.udp {
+		return "", fmt.Errorf("file transfer requires TCP")
+	}
+	conn, _, err := c.session()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
 
-	go io.Copy(conn, os.Stdin)
-	io.Copy(os.Stdout, conn)
+	return ReceiveFile(conn, dir, true)
 }
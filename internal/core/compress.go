@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const compressMagic = "NCZ1"
+
+// Preference order; the initiating side's order decides.
+var compressionAlgorithms = []string{"deflate", "gzip"}
+
+type CompressionStats struct {
+	Algorithm string
+	RawOut    int64
+	WireOut   int64
+	RawIn     int64
+	WireIn    int64
+}
+
+func (s CompressionStats) String() string {
+	ratio := func(raw, wire int64) string {
+		if wire == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.2fx", float64(raw)/float64(wire))
+	}
+	return fmt.Sprintf("Compression (%s): sent %s as %s (%s), received %s as %s (%s)",
+		s.Algorithm,
+		formatBytes(float64(s.RawOut)), formatBytes(float64(s.WireOut)), ratio(s.RawOut, s.WireOut),
+		formatBytes(float64(s.RawIn)), formatBytes(float64(s.WireIn)), ratio(s.RawIn, s.WireIn))
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// CompressedConn compresses everything written to the underlying
+// connection and decompresses everything read from it. Each Write is
+// flushed immediately so interactive sessions stay responsive.
+type CompressedConn struct {
+	net.Conn
+	algorithm string
+
+	wmu sync.Mutex
+	zw  compressWriter
+
+	rmu  sync.Mutex
+	zr   io.Reader
+	wire *countingReader
+
+	rawIn, rawOut, wireOut atomic.Int64
+}
+
+// NegotiateCompression exchanges supported algorithms with the peer and
+// wraps conn with the first one both sides support.
+func NegotiateCompression(conn net.Conn, initiator bool) (*CompressedConn, error) {
+	offer := compressMagic + " " + strings.Join(compressionAlgorithms, ",") + "\n"
+	if _, err := io.WriteString(conn, offer); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := readLine(conn, 256)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("compression negotiation failed: %w", err)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != compressMagic {
+		return nil, errors.New("peer does not support compression")
+	}
+	peer := strings.Split(fields[1], ",")
+
+	local := compressionAlgorithms
+	if !initiator {
+		local, peer = peer, local
+	}
+
+	algorithm := ""
+	for _, a := range local {
+		if containsString(peer, a) {
+			algorithm = a
+			break
+		}
+	}
+	if algorithm == "" {
+		return nil, fmt.Errorf("no common compression algorithm (peer offers %s)", fields[1])
+	}
+
+	c := &CompressedConn{
+		Conn:      conn,
+		algorithm: algorithm,
+		wire:      &countingReader{r: conn},
+	}
+	w := &countingWriter{w: conn, n: &c.wireOut}
+	switch algorithm {
+	case "gzip":
+		c.zw = gzip.NewWriter(w)
+	case "deflate":
+		c.zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+	}
+	return c, nil
+}
+
+// readLine reads byte-by-byte so nothing past the newline is consumed.
+func readLine(r io.Reader, max int) (string, error) {
+	var sb strings.Builder
+	b := make([]byte, 1)
+	for sb.Len() < max {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return sb.String(), nil
+		}
+		sb.WriteByte(b[0])
+	}
+	return "", errors.New("line too long")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CompressedConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	n, err := c.zw.Write(b)
+	c.rawOut.Add(int64(n))
+	if err != nil {
+		return n, err
+	}
+	return n, c.zw.Flush()
+}
+
+func (c *CompressedConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.zr == nil {
+		switch c.algorithm {
+		case "gzip":
+			zr, err := gzip.NewReader(bufio.NewReader(c.wire))
+			if err != nil {
+				return 0, err
+			}
+			c.zr = zr
+		case "deflate":
+			c.zr = flate.NewReader(bufio.NewReader(c.wire))
+		}
+	}
+
+	n, err := c.zr.Read(b)
+	c.rawIn.Add(int64(n))
+	return n, err
+}
+
+func (c *CompressedConn) Close() error {
+	c.wmu.Lock()
+	c.zw.Close()
+	c.wmu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *CompressedConn) Stats() CompressionStats {
+	return CompressionStats{
+		Algorithm: c.algorithm,
+		RawOut:    c.rawOut.Load(),
+		WireOut:   c.wireOut.Load(),
+		RawIn:     c.rawIn.Load(),
+		WireIn:    c.wire.n.Load(),
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// RunCommand runs command with its stdio bound to conn. It is shared by
+// the listening side and the connect-back client.
+func RunCommand(conn io.ReadWriter, command string) error {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil
+	}
+
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdin = conn
+	cmd.Stdout = NewFlusher(conn)
+	cmd.Stderr = NewFlusher(conn)
+	return cmd.Run()
+}
+
+func RunShell(conn io.ReadWriter) error {
+	cmd := exec.Command("/bin/bash", "-i")
+	cmd.Stdin = conn
+	cmd.Stdout = NewFlusher(conn)
+	cmd.Stderr = NewFlusher(conn)
+	return cmd.Run()
+}
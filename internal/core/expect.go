@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Script is a send/expect conversation parsed from a file such as:
+//
+//	timeout 5s
+//	expect /^220 (?P<host>\S+)/
+//	send "EHLO ${host}\r\n"
+//	expect "250 "
+//	sleep 100ms
+//	send "QUIT\r\n"
+//
+// Sends are Go-quoted strings; $1.. refer to the last expect's groups and
+// ${name} to named groups captured by any earlier expect.
+type Script struct {
+	steps []scriptStep
+}
+
+type scriptStep struct {
+	line     int
+	op       string
+	text     string
+	pattern  *regexp.Regexp
+	duration time.Duration
+}
+
+var scriptVarPattern = regexp.MustCompile(`\$\{(\w+)\}|\$(\d+)`)
+
+func ParseScript(r io.Reader) (*Script, error) {
+	script := &Script{}
+	lines := bufio.NewScanner(r)
+
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		op, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		step := scriptStep{line: n, op: op}
+
+		switch op {
+		case "send":
+			text, err := unquoteScriptArg(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			step.text = text
+		case "expect":
+			expr := arg
+			if len(arg) >= 2 && arg[0] == '/' && arg[len(arg)-1] == '/' {
+				expr = arg[1 : len(arg)-1]
+			} else {
+				unquoted, err := unquoteScriptArg(arg)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				expr = unquoted
+			}
+			pattern, err := regexp.Compile("(?m)" + expr)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			step.pattern = pattern
+			step.text = expr
+		case "timeout", "sleep":
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			step.duration = d
+		default:
+			return nil, fmt.Errorf("line %d: unknown step %q", n, op)
+		}
+
+		script.steps = append(script.steps, step)
+	}
+
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+func unquoteScriptArg(arg string) (string, error) {
+	if arg == "" {
+		return "", fmt.Errorf("missing argument")
+	}
+	if arg[0] == '"' || arg[0] == '`' {
+		return strconv.Unquote(arg)
+	}
+	return arg, nil
+}
+
+type readChunk struct {
+	data []byte
+	err  error
+}
+
+// Run executes the script against conn. Progress is written to trace when
+// it is non-nil. The first failing step is returned as an error.
+func (s *Script) Run(conn net.Conn, trace io.Writer) error {
+	if trace == nil {
+		trace = io.Discard
+	}
+
+	chunks := make(chan readChunk, 16)
+	go func() {
+		for {
+			buffer := make([]byte, 4096)
+			n, err := conn.Read(buffer)
+			chunks <- readChunk{data: buffer[:n], err: err}
+			if err != nil {
+				close(chunks)
+				return
+			}
+		}
+	}()
+
+	timeout := 10 * time.Second
+	vars := make(map[string]string)
+	var groups []string
+	var received []byte
+	var readErr error
+
+	for _, step := range s.steps {
+		switch step.op {
+		case "timeout":
+			timeout = step.duration
+
+		case "sleep":
+			time.Sleep(step.duration)
+
+		case "send":
+			text := expandScriptVars(step.text, vars, groups)
+			fmt.Fprintf(trace, "> %q\n", text)
+			if _, err := io.WriteString(conn, text); err != nil {
+				return fmt.Errorf("line %d: send failed: %w", step.line, err)
+			}
+
+		case "expect":
+			deadline := time.NewTimer(timeout)
+			for {
+				if loc := step.pattern.FindSubmatchIndex(received); loc != nil {
+					groups = groups[:0]
+					for i := 0; i < len(loc); i += 2 {
+						group := ""
+						if loc[i] >= 0 {
+							group = string(received[loc[i]:loc[i+1]])
+						}
+						groups = append(groups, group)
+					}
+					for i, name := range step.pattern.SubexpNames() {
+						if name != "" {
+							vars[name] = groups[i]
+						}
+					}
+					fmt.Fprintf(trace, "< %q\n", groups[0])
+					received = received[loc[1]:]
+					break
+				}
+
+				if readErr != nil {
+					deadline.Stop()
+					return fmt.Errorf("line %d: expect %s: connection closed: %v (received %q)",
+						step.line, step.text, readErr, received)
+				}
+
+				select {
+				case chunk, ok := <-chunks:
+					if !ok {
+						readErr = io.EOF
+						continue
+					}
+					received = append(received, chunk.data...)
+					if chunk.err != nil {
+						readErr = chunk.err
+					}
+				case <-deadline.C:
+					return fmt.Errorf("line %d: expect %s: timed out after %s (received %q)",
+						step.line, step.text, timeout, received)
+				}
+			}
+			deadline.Stop()
+		}
+	}
+	return nil
+}
+
+func expandScriptVars(text string, vars map[string]string, groups []string) string {
+	return scriptVarPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		m := scriptVarPattern.FindStringSubmatch(ref)
+		if m[1] != "" {
+			if v, ok := vars[m[1]]; ok {
+				return v
+			}
+			return ref
+		}
+		i, _ := strconv.Atoi(m[2])
+		if i < len(groups) {
+			return groups[i]
+		}
+		return ref
+	})
+}
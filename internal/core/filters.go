@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// StreamFilter wraps a writer with a transformation. Filters compose by
+// wrapping one another; see ApplyFilters.
+type StreamFilter func(io.Writer) io.Writer
+
+// ApplyFilters wraps w so data passes through filters in the given order.
+func ApplyFilters(w io.Writer, filters ...StreamFilter) io.Writer {
+	for i := len(filters) - 1; i >= 0; i-- {
+		w = filters[i](w)
+	}
+	return w
+}
+
+// LineOptions controls line-ending translation for relayed streams.
+type LineOptions struct {
+	CRLF  bool          // send LF as CRLF
+	LF    bool          // show received CRLF as LF
+	Delay time.Duration // pause after each sent line
+}
+
+func (o LineOptions) Outbound(w io.Writer) io.Writer {
+	var filters []StreamFilter
+	if o.CRLF {
+		filters = append(filters, NewCRLFWriter)
+	}
+	if o.Delay > 0 {
+		filters = append(filters, func(w io.Writer) io.Writer {
+			return NewLineDelayWriter(w, o.Delay)
+		})
+	}
+	return ApplyFilters(w, filters...)
+}
+
+func (o LineOptions) Inbound(w io.Writer) io.Writer {
+	if o.LF {
+		return NewLFWriter(w)
+	}
+	return w
+}
+
+// flushFilter writes out anything a filter in w is still holding back,
+// once the stream it filters has ended.
+func flushFilter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+type crlfWriter struct {
+	w      io.Writer
+	lastCR bool
+}
+
+// NewCRLFWriter turns bare LF into CRLF, leaving existing CRLF alone.
+func NewCRLFWriter(w io.Writer) io.Writer {
+	return &crlfWriter{w: w}
+}
+
+func (c *crlfWriter) Write(b []byte) (int, error) {
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte{'\n'}))
+	for _, ch := range b {
+		if ch == '\n' && !c.lastCR {
+			out = append(out, '\r')
+		}
+		out = append(out, ch)
+		c.lastCR = ch == '\r'
+	}
+	if _, err := c.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+type lfWriter struct {
+	w         io.Writer
+	pendingCR bool
+}
+
+// NewLFWriter turns CRLF into LF. A trailing CR is held back until the
+// next write shows whether an LF follows it, or Flush.
+func NewLFWriter(w io.Writer) io.Writer {
+	return &lfWriter{w: w}
+}
+
+func (l *lfWriter) Write(b []byte) (int, error) {
+	out := make([]byte, 0, len(b)+1)
+	if l.pendingCR {
+		if len(b) == 0 || b[0] != '\n' {
+			out = append(out, '\r')
+		}
+		l.pendingCR = false
+	}
+
+	for i, ch := range b {
+		if ch == '\r' {
+			if i == len(b)-1 {
+				l.pendingCR = true
+				continue
+			}
+			if b[i+1] == '\n' {
+				continue
+			}
+		}
+		out = append(out, ch)
+	}
+
+	if len(out) > 0 {
+		if _, err := l.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes a CR held back at the end of the stream.
+func (l *lfWriter) Flush() error {
+	if !l.pendingCR {
+		return nil
+	}
+	l.pendingCR = false
+	_, err := l.w.Write([]byte{'\r'})
+	return err
+}
+
+type lineDelayWriter struct {
+	w     io.Writer
+	delay time.Duration
+}
+
+// NewLineDelayWriter writes one line at a time, sleeping after each
+// complete line.
+func NewLineDelayWriter(w io.Writer, delay time.Duration) io.Writer {
+	return &lineDelayWriter{w: w, delay: delay}
+}
+
+func (d *lineDelayWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			n, err := d.w.Write(b)
+			return written + n, err
+		}
+
+		n, err := d.w.Write(b[:i+1])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[i+1:]
+		time.Sleep(d.delay)
+	}
+	return written, nil
+}
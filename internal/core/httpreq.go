@@ -0,0 +1,262 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	httpOKStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#28CA42")).
+			Bold(true)
+
+	httpRedirectStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#00BFFF")).
+				Bold(true)
+
+	httpErrorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF4444")).
+			Bold(true)
+
+	httpHeaderStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#87CEEB"))
+
+	httpTimingStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262"))
+)
+
+type HTTPRequest struct {
+	Method  string
+	Path    string
+	Host    string
+	Headers []string
+	Body    []byte
+}
+
+// Bytes renders the request as HTTP/1.1 wire format, adding Host,
+// User-Agent and Content-Length unless they were given explicitly.
+func (r HTTPRequest) Bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", r.Method, r.Path)
+
+	has := make(map[string]bool)
+	for _, h := range r.Headers {
+		name, _, _ := strings.Cut(h, ":")
+		has[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	if !has["Host"] {
+		fmt.Fprintf(&b, "Host: %s\r\n", r.Host)
+	}
+	if !has["User-Agent"] {
+		b.WriteString("User-Agent: ncCmdExe\r\n")
+	}
+	if len(r.Body) > 0 && !has["Content-Length"] {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(r.Body))
+	}
+	for _, h := range r.Headers {
+		b.WriteString(h + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(r.Body)
+	return b.Bytes()
+}
+
+// RenderRawRequest executes a raw request template (Go text/template with
+// .Host, .Port and .Path) and normalises the header block to CRLF.
+func RenderRawRequest(raw string, data map[string]any) ([]byte, error) {
+	tmpl, err := template.New("request").Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+
+	text := out.String()
+	head, body, found := strings.Cut(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n")
+	head = strings.ReplaceAll(head, "\n", "\r\n")
+	if !found {
+		return []byte(strings.TrimRight(head, "\r\n") + "\r\n\r\n"), nil
+	}
+	return []byte(head + "\r\n\r\n" + body), nil
+}
+
+type HTTPOptions struct {
+	TLS        bool
+	Insecure   bool
+	ServerName string
+}
+
+type HTTPTiming struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+type HTTPExchange struct {
+	Response *http.Response
+	Body     []byte
+	Received time.Duration
+}
+
+type HTTPResult struct {
+	Exchanges []HTTPExchange
+	Timing    HTTPTiming
+	TLS       *tls.ConnectionState
+}
+
+// HTTP sends all requests back to back on one connection (pipelining)
+// and reads the responses in order.
+func (c *Client) HTTP(requests [][]byte, methods []string, opts HTTPOptions) (*HTTPResult, error) {
+	result := &HTTPResult{}
+	start := time.Now()
+
+	dialer, err := c.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	result.Timing.DNS = time.Since(start)
+
+	conn, err := dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	result.Timing.Connect = time.Since(start) - result.Timing.DNS
+
+	if opts.TLS {
+		serverName := opts.ServerName
+		if serverName == "" {
+			serverName = c.host
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: opts.Insecure,
+		})
+		tlsStart := time.Now()
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		result.Timing.TLS = time.Since(tlsStart)
+		state := tlsConn.ConnectionState()
+		result.TLS = &state
+		conn = tlsConn
+	}
+
+	sent := time.Now()
+	if _, err := conn.Write(bytes.Join(requests, nil)); err != nil {
+		return nil, err
+	}
+
+	first := &firstByteReader{r: conn}
+	reader := bufio.NewReader(first)
+	for i := range requests {
+		method := http.MethodGet
+		if i < len(methods) {
+			method = methods[i]
+		}
+
+		conn.SetReadDeadline(time.Now().Add(time.Duration(c.timeout) * time.Second))
+		resp, err := http.ReadResponse(reader, &http.Request{Method: method})
+		if err != nil {
+			if len(result.Exchanges) > 0 {
+				break
+			}
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil && len(body) == 0 {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+
+		result.Exchanges = append(result.Exchanges, HTTPExchange{
+			Response: resp,
+			Body:     body,
+			Received: time.Since(start),
+		})
+	}
+
+	if !first.at.IsZero() {
+		result.Timing.FirstByte = first.at.Sub(sent)
+	}
+	result.Timing.Total = time.Since(start)
+	return result, nil
+}
+
+type firstByteReader struct {
+	r  io.Reader
+	at time.Time
+}
+
+func (f *firstByteReader) Read(b []byte) (int, error) {
+	n, err := f.r.Read(b)
+	if n > 0 && f.at.IsZero() {
+		f.at = time.Now()
+	}
+	return n, err
+}
+
+func PrintHTTPResult(w io.Writer, result *HTTPResult, showBody bool) {
+	if result.TLS != nil {
+		fmt.Fprintln(w, httpTimingStyle.Render(fmt.Sprintf("TLS %s, %s",
+			tls.VersionName(result.TLS.Version), tls.CipherSuiteName(result.TLS.CipherSuite))))
+	}
+
+	for i, ex := range result.Exchanges {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		resp := ex.Response
+
+		style := httpOKStyle
+		switch {
+		case resp.StatusCode >= 400:
+			style = httpErrorStyle
+		case resp.StatusCode >= 300:
+			style = httpRedirectStyle
+		}
+		fmt.Fprintln(w, style.Render(fmt.Sprintf("%s %s", resp.Proto, resp.Status)))
+
+		names := make([]string, 0, len(resp.Header))
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range resp.Header[name] {
+				fmt.Fprintf(w, "%s %s\n", httpHeaderStyle.Render(name+":"), value)
+			}
+		}
+
+		if showBody && len(ex.Body) > 0 {
+			fmt.Fprintln(w)
+			w.Write(ex.Body)
+			if !bytes.HasSuffix(ex.Body, []byte("\n")) {
+				fmt.Fprintln(w)
+			}
+		}
+		fmt.Fprintln(w, httpTimingStyle.Render(fmt.Sprintf("(%d bytes, received at %s)",
+			len(ex.Body), ex.Received.Round(time.Microsecond))))
+	}
+
+	t := result.Timing
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, httpTimingStyle.Render(fmt.Sprintf("DNS %s | Connect %s | TLS %s | First byte %s | Total %s",
+		t.DNS.Round(time.Microsecond), t.Connect.Round(time.Microsecond), t.TLS.Round(time.Microsecond),
+		t.FirstByte.Round(time.Microsecond), t.Total.Round(time.Microsecond))))
+}
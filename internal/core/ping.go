@@ -0,0 +1,155 @@
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type PingOptions struct {
+	TLS        bool
+	Insecure   bool
+	ServerName string
+}
+
+type PingSample struct {
+	Seq     int
+	Addr    string
+	Connect time.Duration
+	TLS     time.Duration
+	Err     error
+}
+
+// Total is the full time to a usable connection.
+func (p PingSample) Total() time.Duration {
+	return p.Connect + p.TLS
+}
+
+// Ping times one TCP handshake, dialing exactly as TestConnection does,
+// and optionally a TLS handshake on top of it.
+func (c *Client) Ping(seq int, opts PingOptions) PingSample {
+	sample := PingSample{Seq: seq}
+
+	start := time.Now()
+	conn, err := c.Dial()
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	defer conn.Close()
+	sample.Connect = time.Since(start)
+	sample.Addr = conn.RemoteAddr().String()
+
+	if opts.TLS {
+		serverName := opts.ServerName
+		if serverName == "" {
+			serverName = c.host
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: opts.Insecure,
+		})
+		tlsConn.SetDeadline(time.Now().Add(time.Duration(c.timeout) * time.Second))
+		tlsStart := time.Now()
+		if err := tlsConn.Handshake(); err != nil {
+			sample.Err = fmt.Errorf("TLS handshake failed: %w", err)
+			return sample
+		}
+		sample.TLS = time.Since(tlsStart)
+	}
+	return sample
+}
+
+type PingStats struct {
+	Sent     int
+	Received int
+	Times    []time.Duration
+}
+
+func (s *PingStats) Add(sample PingSample) {
+	s.Sent++
+	if sample.Err == nil {
+		s.Received++
+		s.Times = append(s.Times, sample.Total())
+	}
+}
+
+func (s *PingStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) * 100 / float64(s.Sent)
+}
+
+// Summary returns min, avg, max and standard deviation of the replies.
+func (s *PingStats) Summary() (time.Duration, time.Duration, time.Duration, time.Duration) {
+	if len(s.Times) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	minTime, maxTime := s.Times[0], s.Times[0]
+	var sum float64
+	for _, t := range s.Times {
+		minTime = min(minTime, t)
+		maxTime = max(maxTime, t)
+		sum += float64(t)
+	}
+	avg := sum / float64(len(s.Times))
+
+	var variance float64
+	for _, t := range s.Times {
+		variance += (float64(t) - avg) * (float64(t) - avg)
+	}
+	stddev := math.Sqrt(variance / float64(len(s.Times)))
+	return minTime, time.Duration(avg), maxTime, time.Duration(stddev)
+}
+
+func PrintPingStats(w io.Writer, target string, s *PingStats) {
+	fmt.Fprintln(w, clientStyle.Render(fmt.Sprintf("--- %s ping statistics ---", target)))
+	fmt.Fprintf(w, "%d probes sent, %d successful, %.1f%% loss\n", s.Sent, s.Received, s.Loss())
+	if len(s.Times) == 0 {
+		return
+	}
+
+	minTime, avg, maxTime, stddev := s.Summary()
+	fmt.Fprintf(w, "min/avg/max/stddev = %s/%s/%s/%s\n",
+		FormatMillis(minTime), FormatMillis(avg), FormatMillis(maxTime), FormatMillis(stddev))
+	if len(s.Times) > 1 && maxTime > minTime {
+		fmt.Fprintln(w)
+		printHistogram(w, s.Times, minTime, maxTime)
+	}
+}
+
+// FormatMillis renders d in milliseconds with microsecond precision.
+func FormatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+func printHistogram(w io.Writer, times []time.Duration, minTime, maxTime time.Duration) {
+	const buckets = 10
+	const width = 40
+
+	sorted := append([]time.Duration(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	step := (maxTime - minTime) / buckets
+	counts := make([]int, buckets)
+	for _, t := range sorted {
+		i := min(int((t-minTime)/max(step, 1)), buckets-1)
+		counts[i]++
+	}
+
+	peak := 0
+	for _, n := range counts {
+		peak = max(peak, n)
+	}
+	for i, n := range counts {
+		low := minTime + time.Duration(i)*step
+		bar := strings.Repeat("█", n*width/peak)
+		fmt.Fprintf(w, "%12s | %-*s %d\n", FormatMillis(low), width, bar, n)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is an io.Writer that counts bytes passing through it and
+// redraws a single-line progress bar on stderr.
+type Progress struct {
+	label string
+	total int64
+	out   io.Writer
+
+	mu    sync.Mutex
+	done  int64
+	base  int64
+	start time.Time
+	drawn time.Time
+}
+
+func NewProgress(label string, total, offset int64) *Progress {
+	return &Progress{
+		label: label,
+		total: total,
+		out:   os.Stderr,
+		done:  offset,
+		base:  offset,
+		start: time.Now(),
+	}
+}
+
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done += int64(len(b))
+	if time.Since(p.drawn) >= 100*time.Millisecond {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+func (p *Progress) draw() {
+	p.drawn = time.Now()
+
+	rate := 0.0
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		rate = float64(p.done-p.base) / elapsed
+	}
+
+	if p.total <= 0 {
+		fmt.Fprintf(p.out, "\r%s %s  %s/s   ", p.label, formatBytes(float64(p.done)), formatBytes(rate))
+		return
+	}
+
+	const width = 30
+	ratio := float64(p.done) / float64(p.total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	if filled > 0 && filled < width {
+		bar = strings.Repeat("=", filled-1) + ">" + strings.Repeat(" ", width-filled)
+	}
+
+	fmt.Fprintf(p.out, "\r%s [%s] %3.0f%%  %s/%s  %s/s   ",
+		p.label, bar, ratio*100,
+		formatBytes(float64(p.done)), formatBytes(float64(p.total)), formatBytes(rate))
+}
+
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
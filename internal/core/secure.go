@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Handshake, loosely following Noise XX/KK patterns:
+//
+//	each side -> magic, flags, ephemeral X25519 key [, static X25519 key]
+//
+// Keys are derived with HKDF-SHA256 from the ee (and, with static keys, es/se)
+// shared secrets, salted with the transcript hash and bound to the
+// passphrase. Each side then proves it derived the same keys by sending the
+// transcript hash as its first encrypted record.
+
+const (
+	secureMagic      = "NCE1"
+	secureFlagStatic = 1
+	maxRecordSize    = 16 * 1024
+)
+
+type SecureConfig struct {
+	psk        []byte
+	privateKey *ecdh.PrivateKey
+	peerKey    *ecdh.PublicKey
+}
+
+// NewSecureConfig builds an encryption setup from a passphrase and/or a
+// static key pair. privateKeyFile and peerKeyFile hold base64 X25519 keys
+// as written by GenerateKeyPair.
+func NewSecureConfig(passphrase, privateKeyFile, peerKeyFile string) (*SecureConfig, error) {
+	if passphrase == "" && privateKeyFile == "" {
+		return nil, errors.New("encryption needs a passphrase or a static key")
+	}
+
+	config := &SecureConfig{}
+
+	if passphrase != "" {
+		psk, err := scrypt.Key([]byte(passphrase), []byte("ncCmdExe-secure-psk"), 1<<15, 8, 1, 32)
+		if err != nil {
+			return nil, err
+		}
+		config.psk = psk
+	}
+
+	if privateKeyFile != "" {
+		raw, err := readKeyFile(privateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %w", privateKeyFile, err)
+		}
+		config.privateKey = key
+	}
+
+	if peerKeyFile != "" {
+		if config.privateKey == nil {
+			return nil, errors.New("a peer key requires our own static key")
+		}
+		raw, err := readKeyFile(peerKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ecdh.X25519().NewPublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key in %s: %w", peerKeyFile, err)
+		}
+		config.peerKey = key
+	}
+
+	if config.psk == nil && config.peerKey == nil {
+		return nil, errors.New("a static key without a peer key or passphrase cannot authenticate the peer")
+	}
+	return config, nil
+}
+
+// GenerateKeyPair writes base64 X25519 keys to path+".key" and path+".pub".
+func GenerateKeyPair(path string) error {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	private := base64.StdEncoding.EncodeToString(key.Bytes()) + "\n"
+	if err := os.WriteFile(path+".key", []byte(private), 0o600); err != nil {
+		return err
+	}
+	public := base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()) + "\n"
+	return os.WriteFile(path+".pub", []byte(public), 0o644)
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+type SecureConn struct {
+	net.Conn
+
+	wmu   sync.Mutex
+	send  cipherState
+	rmu   sync.Mutex
+	recv  cipherState
+	plain []byte
+}
+
+type cipherState struct {
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+func (c *cipherState) next() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[4:], c.nonce)
+	c.nonce++
+	return nonce
+}
+
+func SecureHandshake(conn net.Conn, config *SecureConfig, initiator bool) (*SecureConn, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	hello := []byte(secureMagic)
+	if config.privateKey != nil {
+		hello = append(hello, secureFlagStatic)
+		hello = append(hello, ephemeral.PublicKey().Bytes()...)
+		hello = append(hello, config.privateKey.PublicKey().Bytes()...)
+	} else {
+		hello = append(hello, 0)
+		hello = append(hello, ephemeral.PublicKey().Bytes()...)
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(hello); err != nil {
+		return nil, err
+	}
+
+	peerHello := make([]byte, len(secureMagic)+1+32)
+	if _, err := io.ReadFull(conn, peerHello); err != nil {
+		return nil, fmt.Errorf("secure handshake failed: %w", err)
+	}
+	if string(peerHello[:len(secureMagic)]) != secureMagic {
+		return nil, errors.New("peer is not using encryption")
+	}
+
+	var peerStatic *ecdh.PublicKey
+	if peerHello[len(secureMagic)]&secureFlagStatic != 0 {
+		raw := make([]byte, 32)
+		if _, err := io.ReadFull(conn, raw); err != nil {
+			return nil, fmt.Errorf("secure handshake failed: %w", err)
+		}
+		peerHello = append(peerHello, raw...)
+		if peerStatic, err = ecdh.X25519().NewPublicKey(raw); err != nil {
+			return nil, err
+		}
+	}
+	if config.peerKey != nil && (peerStatic == nil || !peerStatic.Equal(config.peerKey)) {
+		return nil, errors.New("peer did not present the expected static key")
+	}
+
+	peerEphemeral, err := ecdh.X25519().NewPublicKey(peerHello[len(secureMagic)+1 : len(secureMagic)+33])
+	if err != nil {
+		return nil, err
+	}
+
+	ikm, err := ephemeral.ECDH(peerEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	if config.privateKey != nil && peerStatic != nil {
+		es, err := ephemeral.ECDH(peerStatic)
+		if err != nil {
+			return nil, err
+		}
+		se, err := config.privateKey.ECDH(peerEphemeral)
+		if err != nil {
+			return nil, err
+		}
+		// Order by role so both sides concatenate identically.
+		if initiator {
+			ikm = append(append(ikm, es...), se...)
+		} else {
+			ikm = append(append(ikm, se...), es...)
+		}
+	}
+
+	transcript := sha256.New()
+	transcript.Write([]byte("ncCmdExe-secure-v1"))
+	if initiator {
+		transcript.Write(hello)
+		transcript.Write(peerHello)
+	} else {
+		transcript.Write(peerHello)
+		transcript.Write(hello)
+	}
+	h := transcript.Sum(nil)
+
+	keys := make([]byte, 2*chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, h, config.psk), keys); err != nil {
+		return nil, err
+	}
+
+	sendKey, recvKey := keys[:32], keys[32:]
+	if !initiator {
+		sendKey, recvKey = recvKey, sendKey
+	}
+	sc := &SecureConn{Conn: conn}
+	if sc.send.aead, err = chacha20poly1305.New(sendKey); err != nil {
+		return nil, err
+	}
+	if sc.recv.aead, err = chacha20poly1305.New(recvKey); err != nil {
+		return nil, err
+	}
+
+	if _, err := sc.Write(h); err != nil {
+		return nil, err
+	}
+	confirm := make([]byte, len(h))
+	if _, err := io.ReadFull(sc, confirm); err != nil || !bytes.Equal(confirm, h) {
+		return nil, errors.New("secure handshake failed: wrong passphrase or key")
+	}
+	return sc, nil
+}
+
+func (c *SecureConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	aead := c.send.aead
+	written := 0
+	for len(b) > 0 {
+		n := len(b)
+		if n > maxRecordSize {
+			n = maxRecordSize
+		}
+
+		record := make([]byte, 2, 2+n+aead.Overhead())
+		record = aead.Seal(record, c.send.next(), b[:n], nil)
+		binary.BigEndian.PutUint16(record, uint16(len(record)-2))
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (c *SecureConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if len(c.plain) == 0 {
+		var size [2]byte
+		if _, err := io.ReadFull(c.Conn, size[:]); err != nil {
+			return 0, err
+		}
+		record := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(c.Conn, record); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		plain, err := c.recv.aead.Open(record[:0], c.recv.next(), record, nil)
+		if err != nil {
+			return 0, errors.New("secure channel: message authentication failed")
+		}
+		c.plain = plain
+	}
+
+	n := copy(b, c.plain)
+	c.plain = c.plain[n:]
+	return n, nil
+}
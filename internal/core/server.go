@@ -2,110 +2,379 @@ package core
 
 import (
 	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/exec"
-	"strings"
-
-	"github.com/charmbracelet/lipgloss"
-)
-
-var (
-	serverStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#00FF00")).
-		Bold(true)
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
-	port    int
-	udp     bool
-	execute string
-	shell   bool
+	name     string
+	network  string
+	address  string
+	execute  string
+	shell    bool
+	forward  string
+	recvDir  string
+	sendPath string
+	bench    bool
+	once     bool
+	udpIdle  time.Duration
+	compress bool
+	secure   *SecureConfig
+	lines    LineOptions
+	shape    ShapeOptions
+	tls      *tls.Config
+	acl      *ACL
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+type ServerConfig struct {
+	Name       string
+	Network    string
+	Address    string
+	Execute    string
+	Shell      bool
+	Forward    string
+	ReceiveDir string
+	SendPath   string
+	Bench      bool
+	Once       bool
+	UDPIdle    time.Duration // close UDP sessions silent this long, default 2m
+	Compress   bool
+	Secure     *SecureConfig
+	Lines      LineOptions
+	Shape      ShapeOptions
+	TLS        *tls.Config
+	ACL        *ACL
+	Logger     *slog.Logger
 }
 
 func NewServer(port int, udp bool, execute string, shell bool) *Server {
+	network := "tcp"
+	if udp {
+		network = "udp"
+	}
+
+	return NewServerWithConfig(ServerConfig{
+		Network: network,
+		Address: fmt.Sprintf(":%d", port),
+		Execute: execute,
+		Shell:   shell,
+	})
+}
+
+func NewServerWithConfig(config ServerConfig) *Server {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.Name != "" {
+		config.Logger = config.Logger.With("service", config.Name)
+	}
+
 	return &Server{
-		port:    port,
-		udp:     udp,
-		execute: execute,
-		shell:   shell,
+		name:     config.Name,
+		network:  config.Network,
+		address:  config.Address,
+		execute:  config.Execute,
+		shell:    config.Shell,
+		forward:  config.Forward,
+		recvDir:  config.ReceiveDir,
+		sendPath: config.SendPath,
+		bench:    config.Bench,
+		once:     config.Once,
+		udpIdle:  config.UDPIdle,
+		compress: config.Compress,
+		secure:   config.Secure,
+		lines:    config.Lines,
+		shape:    config.Shape,
+		tls:      config.TLS,
+		acl:      config.ACL,
+		logger:   config.Logger,
 	}
 }
 
 func (s *Server) Start() {
-	protocol := "tcp"
-	if s.udp {
-		protocol = "udp"
+	if err := s.ListenAndServe(); err != nil {
+		s.logger.Error("failed to listen", "address", s.address, "error", err)
+		os.Exit(1)
 	}
+}
 
-	addr := fmt.Sprintf(":%d", s.port)
-	listener, err := net.Listen(protocol, addr)
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+func (s *Server) ListenAndServe() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+func (s *Server) Listen() error {
+	var listener net.Listener
+
+	switch s.network {
+	case "udp", "udp4", "udp6":
+		if s.compress || s.secure != nil {
+			return errors.New("compression and encryption require a stream transport")
+		}
+		pc, err := net.ListenPacket(s.network, s.address)
+		if err != nil {
+			return err
+		}
+		listener = newUDPListener(pc, s.udpIdle)
+	case "unix":
+		if info, err := os.Stat(s.address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(s.address)
+		}
+		l, err := net.Listen(s.network, s.address)
+		if err != nil {
+			return err
+		}
+		listener = l
+	default:
+		l, err := net.Listen(s.network, s.address)
+		if err != nil {
+			return err
+		}
+		listener = l
+	}
+
+	if s.tls != nil {
+		listener = tls.NewListener(listener, s.tls)
 	}
-	defer listener.Close()
 
-	fmt.Println(serverStyle.Render(fmt.Sprintf("Server listening on %s://%s", protocol, addr)))
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	scheme := s.network
+	if s.tls != nil {
+		scheme += "+tls"
+	}
+	s.logger.Info("server listening", "network", scheme, "address", listener.Addr().String())
+	return nil
+}
+
+func (s *Server) Serve() error {
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+	if listener == nil {
+		return errors.New("server is not listening")
+	}
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Panicf("Failed to accept connections: %v", err)
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.logger.Warn("failed to accept connection", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		if !s.acl.Permits(conn.RemoteAddr()) {
+			s.logger.Warn("connection rejected by ACL", "remote", conn.RemoteAddr().String())
+			conn.Close()
 			continue
 		}
 
+		if s.once {
+			err := s.handleConnection(conn)
+			s.Close()
+			return err
+		}
 		go s.handleConnection(conn)
 	}
 }
 
-func (s *Server) handleConnection(conn net.Conn) {
-	defer conn.Close()
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	clientAddr := conn.RemoteAddr().String()
-	fmt.Println(serverStyle.Render(fmt.Sprintf("New connection from %s", clientAddr)))
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.listener = nil
+	return err
+}
+
+// handleConnection serves one connection. Its error, returned for file
+// transfers and failed handshakes, is what a one-shot server exits with.
+func (s *Server) handleConnection(raw net.Conn) error {
+	counted := &countingConn{Conn: raw}
+	var conn net.Conn = counted
+	defer func() { conn.Close() }()
+
+	start := time.Now()
+	log := s.logger.With("session", newSessionID(), "remote", raw.RemoteAddr().String())
+	log.Info("connection opened")
+	defer func() {
+		log.Info("connection closed",
+			"bytes_in", counted.bytesIn.Load(),
+			"bytes_out", counted.bytesOut.Load(),
+			"duration", time.Since(start))
+	}()
+
+	if s.secure != nil {
+		sc, err := SecureHandshake(conn, s.secure, false)
+		if err != nil {
+			log.Warn("secure handshake failed", "error", err)
+			return err
+		}
+		conn = sc
+	}
+
+	if s.compress {
+		cc, err := NegotiateCompression(conn, false)
+		if err != nil {
+			log.Warn("compression negotiation failed", "error", err)
+			return err
+		}
+		defer func() { fmt.Fprintln(os.Stderr, cc.Stats()) }()
+		conn = cc
+	}
 
 	if s.execute != "" {
-		s.executeCommand(conn, s.execute)
+		s.executeCommand(conn, s.execute, log)
 	} else if s.shell {
-		s.spawnShell(conn)
+		s.spawnShell(conn, log)
+	} else if s.forward != "" {
+		s.forwardTo(conn, s.forward, log)
+	} else if s.recvDir != "" {
+		return s.receiveFile(conn, log)
+	} else if s.sendPath != "" {
+		return s.sendFile(conn, log)
+	} else if s.bench {
+		s.benchServe(conn, log)
 	} else {
 		s.relay(conn)
 	}
+	return nil
 }
 
-func (s *Server) executeCommand(conn net.Conn, command string) {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
+func (s *Server) executeCommand(conn net.Conn, command string, log *slog.Logger) {
+	err := RunCommand(conn, command)
+	if err != nil {
+		fmt.Fprintf(conn, "Error executing command: %v\n", err)
+	}
+	logExit(log, "command finished", err, "command", command)
+}
+
+func (s *Server) spawnShell(conn net.Conn, log *slog.Logger) {
+	err := RunShell(conn)
+	if err != nil {
+		fmt.Fprintf(conn, "Error spawing shell: %v\n", err)
+	}
+	logExit(log, "shell finished", err)
+}
+
+func (s *Server) relay(conn net.Conn) {
+	conn = NewShapedConn(conn, s.shape)
+	defer conn.Close()
+
+	go io.Copy(s.lines.Outbound(conn), os.Stdin)
+	inbound := s.lines.Inbound(os.Stdout)
+	io.Copy(inbound, conn)
+	flushFilter(inbound)
+}
+
+func (s *Server) forwardTo(conn net.Conn, target string, log *slog.Logger) {
+	upstream, err := net.DialTimeout("tcp", target, 10*time.Second)
+	if err != nil {
+		log.Warn("forward failed", "target", target, "error", err)
 		return
 	}
+	defer upstream.Close()
+	log.Debug("forwarding", "target", target)
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Stdin = conn
-	cmd.Stdout = NewFlusher(conn)
-	cmd.Stderr = NewFlusher(conn)
+	conn = NewShapedConn(conn, s.shape)
+	defer conn.Close()
 
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintf(conn, "Error executing command: %v\n", err)
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func (s *Server) receiveFile(conn net.Conn, log *slog.Logger) error {
+	path, err := ReceiveFile(conn, s.recvDir, s.name == "")
+	if err != nil {
+		log.Warn("transfer failed", "error", err)
+		return err
 	}
+	log.Info("file received", "path", path)
+	return nil
 }
 
-func (s *Server) spawnShell(conn net.Conn) {
-	cmd := exec.Command("/bin/bash", "-i")
-	cmd.Stdin = conn
-	cmd.Stdout = NewFlusher(conn)
-	cmd.Stderr = NewFlusher(conn)
+func (s *Server) sendFile(conn net.Conn, log *slog.Logger) error {
+	if err := SendFile(conn, s.sendPath, s.name == ""); err != nil {
+		log.Warn("transfer failed", "error", err)
+		return err
+	}
+	log.Info("file sent", "path", s.sendPath)
+	return nil
+}
 
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintf(conn, "Error spawing shell: %v\n", err)
+// logExit records how a command or shell ended, including its exit status
+// when it ran at all.
+func logExit(log *slog.Logger, msg string, err error, args ...any) {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		log.Info(msg, append(args, "exit_status", 0)...)
+	case errors.As(err, &exitErr):
+		log.Info(msg, append(args, "exit_status", exitErr.ExitCode())...)
+	default:
+		log.Warn(msg, append(args, "error", err)...)
 	}
 }
 
-func (s *Server) relay(conn net.Conn) {
-	go io.Copy(conn, os.Stdin)
-	io.Copy(os.Stdout, conn)
+func newSessionID() string {
+	var id [4]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// countingConn counts the raw bytes read from and written to a connection.
+type countingConn struct {
+	net.Conn
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.bytesIn.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.bytesOut.Add(int64(n))
+	return n, err
 }
 
 type Flusher struct {
@@ -0,0 +1,358 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ServicesFile struct {
+	LogFile  string          `yaml:"log_file"`
+	Services []ServiceConfig `yaml:"services"`
+}
+
+type ServiceConfig struct {
+	Name     string            `yaml:"name"`
+	Network  string            `yaml:"network"`
+	Address  string            `yaml:"address"`
+	Execute  string            `yaml:"execute"`
+	Shell    bool              `yaml:"shell"`
+	Forward  string            `yaml:"forward"`
+	Receive  string            `yaml:"receive_dir"`
+	Send     string            `yaml:"send_path"`
+	Compress bool              `yaml:"compress"`
+	UDPIdle  time.Duration     `yaml:"udp_idle_timeout"`
+	Secure   *SecureFileConfig `yaml:"secure"`
+	TLS      *TLSConfig        `yaml:"tls"`
+	Shape    *ShapeFileConfig  `yaml:"shape"`
+	Allow    []string          `yaml:"allow"`
+	Deny     []string          `yaml:"deny"`
+}
+
+type SecureFileConfig struct {
+	Passphrase string `yaml:"passphrase"`
+	Key        string `yaml:"key"`
+	PeerKey    string `yaml:"peer_key"`
+}
+
+type ShapeFileConfig struct {
+	Rate    int64         `yaml:"rate"`
+	Latency time.Duration `yaml:"latency"`
+	Jitter  time.Duration `yaml:"jitter"`
+	Drop    float64       `yaml:"drop"` // percent
+}
+
+type TLSConfig struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"`
+}
+
+func LoadServices(path string) (*ServicesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ServicesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Services {
+		svc := &file.Services[i]
+		if svc.Name == "" {
+			return nil, fmt.Errorf("service #%d has no name", i+1)
+		}
+		if seen[svc.Name] {
+			return nil, fmt.Errorf("duplicate service name %q", svc.Name)
+		}
+		seen[svc.Name] = true
+
+		if err := svc.validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %w", svc.Name, err)
+		}
+	}
+
+	return &file, nil
+}
+
+func (c *ServiceConfig) validate() error {
+	if c.Network == "" {
+		c.Network = "tcp"
+	}
+	switch c.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	case "udp", "udp4", "udp6":
+		if c.TLS != nil || c.Compress || c.Secure != nil {
+			return fmt.Errorf("tls, compress and secure are not supported on %s", c.Network)
+		}
+	default:
+		return fmt.Errorf("unsupported network %q", c.Network)
+	}
+
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+
+	modes := 0
+	for _, set := range []bool{c.Execute != "", c.Shell, c.Forward != "", c.Receive != "", c.Send != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("execute, shell, forward, receive_dir and send_path are mutually exclusive")
+	}
+
+	if c.TLS != nil && (c.TLS.Cert == "" || c.TLS.Key == "") {
+		return fmt.Errorf("tls requires cert and key")
+	}
+	if c.Shape != nil && (c.Shape.Rate < 0 || c.Shape.Drop < 0 || c.Shape.Drop > 100) {
+		return fmt.Errorf("shape rate must be positive and drop between 0 and 100")
+	}
+	return nil
+}
+
+func (c *ServiceConfig) serverConfig(logger *slog.Logger) (ServerConfig, error) {
+	acl, err := NewACL(c.Allow, c.Deny)
+	if err != nil {
+		return ServerConfig{}, err
+	}
+
+	config := ServerConfig{
+		Name:       c.Name,
+		Network:    c.Network,
+		Address:    c.Address,
+		Execute:    c.Execute,
+		Shell:      c.Shell,
+		Forward:    c.Forward,
+		ReceiveDir: c.Receive,
+		SendPath:   c.Send,
+		Compress:   c.Compress,
+		UDPIdle:    c.UDPIdle,
+		ACL:        acl,
+		Logger:     logger,
+	}
+
+	if c.Secure != nil {
+		secure, err := NewSecureConfig(c.Secure.Passphrase, c.Secure.Key, c.Secure.PeerKey)
+		if err != nil {
+			return ServerConfig{}, err
+		}
+		config.Secure = secure
+	}
+
+	if c.Shape != nil {
+		config.Shape = ShapeOptions{
+			Rate:    c.Shape.Rate,
+			Latency: c.Shape.Latency,
+			Jitter:  c.Shape.Jitter,
+			Drop:    c.Shape.Drop / 100,
+		}
+	}
+
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.load()
+		if err != nil {
+			return ServerConfig{}, err
+		}
+		config.TLS = tlsConfig
+	}
+	return config, nil
+}
+
+func (t *TLSConfig) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if t.ClientCA != "" {
+		pem, err := os.ReadFile(t.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ServiceHost runs every service from a config file and reconciles the
+// running set with the file whenever Reload is called.
+type ServiceHost struct {
+	path   string
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	running map[string]*runningService
+}
+
+type runningService struct {
+	config ServiceConfig
+	server *Server
+}
+
+func NewServiceHost(path string, logger *slog.Logger) *ServiceHost {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ServiceHost{
+		path:    path,
+		logger:  logger,
+		running: make(map[string]*runningService),
+	}
+}
+
+// Reload reconciles the running services with the config file. Services
+// whose config is unchanged keep running; a changed service keeps its old
+// listener until the replacement is listening, so a bad certificate or a
+// busy port leaves the old one serving.
+func (h *ServiceHost) Reload() error {
+	file, err := LoadServices(h.path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, svc := range file.Services {
+		wanted[svc.Name] = true
+	}
+	for name, rs := range h.running {
+		if !wanted[name] {
+			h.logger.Info("stopping service", "service", name)
+			rs.server.Close()
+			delete(h.running, name)
+		}
+	}
+
+	var errs []error
+	for _, svc := range file.Services {
+		if rs, exists := h.running[svc.Name]; exists && reflect.DeepEqual(svc, rs.config) {
+			continue
+		}
+		if err := h.start(svc); err != nil {
+			h.logger.Error("failed to start service", "service", svc.Name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", svc.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d services failed to start: %v", len(errs), len(file.Services), errs)
+	}
+	return nil
+}
+
+// start runs svc, replacing the running service of the same name once the
+// new listener is up. When the new listener needs the old one's address,
+// the old one is closed first and brought back if the new one fails.
+func (h *ServiceHost) start(svc ServiceConfig) error {
+	config, err := svc.serverConfig(h.logger)
+	if err != nil {
+		return err
+	}
+
+	server := NewServerWithConfig(config)
+	old := h.running[svc.Name]
+	sameAddress := old != nil && old.config.Network == svc.Network && old.config.Address == svc.Address
+
+	// A unix socket path can't be shared: binding unlinks the old socket
+	// and closing the old listener would unlink the new one.
+	if !sameAddress || svc.Network != "unix" {
+		err = server.Listen()
+	}
+	if sameAddress && (svc.Network == "unix" || err != nil) {
+		old.server.Close()
+		if err = server.Listen(); err != nil {
+			if relistenErr := old.server.Listen(); relistenErr != nil {
+				delete(h.running, svc.Name)
+				return fmt.Errorf("%w (and the old service could not restart: %v)", err, relistenErr)
+			}
+			h.serve(svc.Name, old.server)
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if old != nil {
+		h.logger.Info("replacing service", "service", svc.Name)
+		old.server.Close()
+	}
+	h.running[svc.Name] = &runningService{config: svc, server: server}
+	h.serve(svc.Name, server)
+	return nil
+}
+
+func (h *ServiceHost) serve(name string, server *Server) {
+	go func() {
+		if err := server.Serve(); err != nil {
+			h.logger.Error("service stopped", "service", name, "error", err)
+		}
+	}()
+}
+
+func (h *ServiceHost) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, rs := range h.running {
+		rs.server.Close()
+		delete(h.running, name)
+	}
+}
+
+// Run starts all services and blocks, reloading on SIGHUP and stopping
+// on SIGINT/SIGTERM.
+func (h *ServiceHost) Run() error {
+	if err := h.Reload(); err != nil {
+		h.mu.Lock()
+		started := len(h.running)
+		h.mu.Unlock()
+		if started == 0 {
+			return err
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			h.logger.Info("shutting down", "signal", sig.String())
+			h.Stop()
+			return nil
+		}
+
+		h.logger.Info("reloading", "config", h.path)
+		if err := h.Reload(); err != nil {
+			h.logger.Error("reload failed", "error", err)
+		}
+	}
+	return nil
+}
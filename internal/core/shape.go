@@ -0,0 +1,254 @@
+package core
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShapeOptions simulates a slow or lossy link. Each option applies to both
+// directions of a shaped connection.
+type ShapeOptions struct {
+	Rate    int64         // bytes per second, 0 for unlimited
+	Latency time.Duration // added one-way delay
+	Jitter  time.Duration // random +/- variation of Latency
+	Drop    float64       // probability (0-1) of dropping a packet
+}
+
+func (o ShapeOptions) Enabled() bool {
+	return o.Rate > 0 || o.Latency > 0 || o.Jitter > 0 || o.Drop > 0
+}
+
+// shapePacketSize is the unit stream data is cut into for rate limiting,
+// delays and drops; roughly one Ethernet TCP segment.
+const shapePacketSize = 1460
+
+type shapedPacket struct {
+	data []byte
+	due  time.Time
+	err  error
+}
+
+// shaper paces one direction of a connection.
+type shaper struct {
+	opts     ShapeOptions
+	datagram bool
+
+	mu      sync.Mutex
+	tokens  float64
+	filled  time.Time
+	lastDue time.Time
+	rnd     *rand.Rand
+}
+
+func newShaper(opts ShapeOptions, datagram bool) *shaper {
+	return &shaper{
+		opts:     opts,
+		datagram: datagram,
+		filled:   time.Now(),
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// take blocks until n bytes fit in the token bucket.
+func (s *shaper) take(n int) {
+	if s.opts.Rate <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	burst := float64(max(s.opts.Rate/10, shapePacketSize))
+	s.tokens = min(burst, s.tokens+now.Sub(s.filled).Seconds()*float64(s.opts.Rate))
+	s.filled = now
+	s.tokens -= float64(n)
+	wait := time.Duration(-s.tokens / float64(s.opts.Rate) * float64(time.Second))
+	s.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// schedule returns when a packet sent now should be delivered, or false if
+// it is dropped. Over a stream a dropped packet cannot simply vanish, so it
+// is delayed by a retransmission timeout instead, as TCP would.
+func (s *shaper) schedule() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(s.rnd.Int63n(int64(2*s.opts.Jitter))) - s.opts.Jitter
+	}
+	if s.opts.Drop > 0 && s.rnd.Float64() < s.opts.Drop {
+		if s.datagram {
+			return time.Time{}, false
+		}
+		delay += max(2*s.opts.Latency, 200*time.Millisecond)
+	}
+
+	due := time.Now().Add(max(delay, 0))
+	if !s.datagram && due.Before(s.lastDue) {
+		// streams never reorder
+		due = s.lastDue
+	}
+	s.lastDue = due
+	return due, true
+}
+
+// packets splits b into the units the shaper works on.
+func (s *shaper) packets(b []byte) [][]byte {
+	if s.datagram {
+		return [][]byte{b}
+	}
+	var out [][]byte
+	for len(b) > 0 {
+		n := min(len(b), shapePacketSize)
+		out = append(out, b[:n])
+		b = b[n:]
+	}
+	return out
+}
+
+// ShapedConn applies ShapeOptions to a net.Conn. Writes are paced and
+// handed to a delivery goroutine; reads are paced as data arrives.
+type ShapedConn struct {
+	net.Conn
+	out *shaper
+	in  *shaper
+
+	writeMu sync.Mutex
+	closed  bool
+	outbox  chan shapedPacket
+	flushed chan struct{}
+
+	errMu    sync.Mutex
+	writeErr error
+
+	readOnce sync.Once
+	inbox    chan shapedPacket
+	pending  []byte
+	readErr  error
+}
+
+// NewShapedConn wraps conn, or returns it unchanged when opts is empty.
+func NewShapedConn(conn net.Conn, opts ShapeOptions) net.Conn {
+	if !opts.Enabled() {
+		return conn
+	}
+
+	datagram := strings.HasPrefix(conn.LocalAddr().Network(), "udp")
+	sc := &ShapedConn{
+		Conn:    conn,
+		out:     newShaper(opts, datagram),
+		in:      newShaper(opts, datagram),
+		outbox:  make(chan shapedPacket, 256),
+		flushed: make(chan struct{}),
+		inbox:   make(chan shapedPacket, 256),
+	}
+	go sc.deliver()
+	return sc
+}
+
+func (c *ShapedConn) deliver() {
+	defer close(c.flushed)
+	for p := range c.outbox {
+		time.Sleep(time.Until(p.due))
+		if c.writeError() != nil {
+			continue
+		}
+		if _, err := c.Conn.Write(p.data); err != nil {
+			c.errMu.Lock()
+			c.writeErr = err
+			c.errMu.Unlock()
+		}
+	}
+}
+
+func (c *ShapedConn) writeError() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.writeErr
+}
+
+func (c *ShapedConn) Write(b []byte) (int, error) {
+	for _, p := range c.out.packets(b) {
+		c.out.take(len(p))
+		due, ok := c.out.schedule()
+		if !ok {
+			continue
+		}
+
+		c.writeMu.Lock()
+		if c.closed {
+			c.writeMu.Unlock()
+			return 0, net.ErrClosed
+		}
+		if err := c.writeError(); err != nil {
+			c.writeMu.Unlock()
+			return 0, err
+		}
+		c.outbox <- shapedPacket{data: append([]byte(nil), p...), due: due}
+		c.writeMu.Unlock()
+	}
+	return len(b), nil
+}
+
+func (c *ShapedConn) receive() {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := c.Conn.Read(buffer)
+		for _, p := range c.in.packets(buffer[:n]) {
+			c.in.take(len(p))
+			if due, ok := c.in.schedule(); ok {
+				c.inbox <- shapedPacket{data: append([]byte(nil), p...), due: due}
+			}
+		}
+		if err != nil {
+			c.inbox <- shapedPacket{err: err, due: time.Now()}
+			close(c.inbox)
+			return
+		}
+	}
+}
+
+func (c *ShapedConn) Read(b []byte) (int, error) {
+	c.readOnce.Do(func() { go c.receive() })
+
+	if len(c.pending) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		p, ok := <-c.inbox
+		if !ok {
+			return 0, io.EOF
+		}
+		time.Sleep(time.Until(p.due))
+		if p.err != nil {
+			c.readErr = p.err
+			return 0, p.err
+		}
+		c.pending = p.data
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Close delivers data still in flight before closing the connection.
+func (c *ShapedConn) Close() error {
+	c.writeMu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.outbox)
+	}
+	c.writeMu.Unlock()
+
+	<-c.flushed
+	return c.Conn.Close()
+}
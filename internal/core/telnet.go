@@ -0,0 +1,242 @@
+package core
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/x/term"
+)
+
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptEcho  = 1
+	telnetOptSGA   = 3
+	telnetOptTType = 24
+	telnetOptNAWS  = 31
+
+	telnetTTypeIS   = 0
+	telnetTTypeSend = 1
+)
+
+type telnetState int
+
+const (
+	telnetData telnetState = iota
+	telnetCommand
+	telnetOption
+	telnetSubneg
+	telnetSubnegIAC
+)
+
+// TelnetConn strips telnet IAC sequences from the stream, answers option
+// negotiation (terminal type and window size are offered, remote echo and
+// suppress-go-ahead accepted, everything else refused) and escapes 0xFF
+// bytes on write.
+type TelnetConn struct {
+	net.Conn
+	termType string
+
+	wmu sync.Mutex
+
+	state   telnetState
+	verb    byte
+	subneg  []byte
+	local   map[byte]bool // options we have agreed to perform
+	remote  map[byte]bool // options the server has agreed to perform
+	pending []byte
+}
+
+func NewTelnetConn(conn net.Conn, termType string) *TelnetConn {
+	if termType == "" {
+		termType = "xterm"
+	}
+	return &TelnetConn{
+		Conn:     conn,
+		termType: termType,
+		local:    make(map[byte]bool),
+		remote:   make(map[byte]bool),
+	}
+}
+
+func (t *TelnetConn) Read(b []byte) (int, error) {
+	for {
+		if len(t.pending) > 0 {
+			n := copy(b, t.pending)
+			t.pending = t.pending[n:]
+			return n, nil
+		}
+
+		raw := make([]byte, len(b))
+		n, err := t.Conn.Read(raw)
+		if n > 0 {
+			data, reply := t.parse(raw[:n])
+			if len(reply) > 0 {
+				if _, werr := t.writeRaw(reply); werr != nil {
+					return 0, werr
+				}
+			}
+			t.pending = data
+		}
+		if err != nil {
+			if len(t.pending) > 0 {
+				n := copy(b, t.pending)
+				t.pending = t.pending[n:]
+				return n, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+func (t *TelnetConn) Write(b []byte) (int, error) {
+	escaped := bytes.ReplaceAll(b, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	if _, err := t.writeRaw(escaped); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (t *TelnetConn) writeRaw(b []byte) (int, error) {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	return t.Conn.Write(b)
+}
+
+// parse returns the application data in raw and the negotiation replies
+// it triggered.
+func (t *TelnetConn) parse(raw []byte) (data, reply []byte) {
+	for _, ch := range raw {
+		switch t.state {
+		case telnetData:
+			if ch == telnetIAC {
+				t.state = telnetCommand
+			} else {
+				data = append(data, ch)
+			}
+
+		case telnetCommand:
+			switch ch {
+			case telnetIAC:
+				data = append(data, telnetIAC)
+				t.state = telnetData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				t.verb = ch
+				t.state = telnetOption
+			case telnetSB:
+				t.subneg = t.subneg[:0]
+				t.state = telnetSubneg
+			default:
+				// NOP, GA, DM, AYT and friends carry nothing for us.
+				t.state = telnetData
+			}
+
+		case telnetOption:
+			reply = append(reply, t.negotiate(t.verb, ch)...)
+			t.state = telnetData
+
+		case telnetSubneg:
+			if ch == telnetIAC {
+				t.state = telnetSubnegIAC
+			} else {
+				t.subneg = append(t.subneg, ch)
+			}
+
+		case telnetSubnegIAC:
+			switch ch {
+			case telnetSE:
+				reply = append(reply, t.subnegotiate(t.subneg)...)
+				t.state = telnetData
+			case telnetIAC:
+				t.subneg = append(t.subneg, telnetIAC)
+				t.state = telnetSubneg
+			default:
+				t.state = telnetSubneg
+			}
+		}
+	}
+	return data, reply
+}
+
+// negotiate only answers when an option's state actually changes, which
+// keeps both sides from looping on acknowledgements.
+func (t *TelnetConn) negotiate(verb, option byte) []byte {
+	switch verb {
+	case telnetDO:
+		if t.local[option] {
+			return nil
+		}
+		switch option {
+		case telnetOptTType:
+			t.local[option] = true
+			return []byte{telnetIAC, telnetWILL, option}
+		case telnetOptNAWS:
+			t.local[option] = true
+			return append([]byte{telnetIAC, telnetWILL, option}, t.windowSize()...)
+		}
+		return []byte{telnetIAC, telnetWONT, option}
+
+	case telnetDONT:
+		if !t.local[option] {
+			return nil
+		}
+		t.local[option] = false
+		return []byte{telnetIAC, telnetWONT, option}
+
+	case telnetWILL:
+		if t.remote[option] {
+			return nil
+		}
+		if option == telnetOptEcho || option == telnetOptSGA {
+			t.remote[option] = true
+			return []byte{telnetIAC, telnetDO, option}
+		}
+		return []byte{telnetIAC, telnetDONT, option}
+
+	case telnetWONT:
+		if !t.remote[option] {
+			return nil
+		}
+		t.remote[option] = false
+		return []byte{telnetIAC, telnetDONT, option}
+	}
+	return nil
+}
+
+func (t *TelnetConn) subnegotiate(sub []byte) []byte {
+	if len(sub) >= 2 && sub[0] == telnetOptTType && sub[1] == telnetTTypeSend {
+		reply := []byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeIS}
+		reply = append(reply, t.termType...)
+		return append(reply, telnetIAC, telnetSE)
+	}
+	return nil
+}
+
+func (t *TelnetConn) windowSize() []byte {
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	reply := []byte{telnetIAC, telnetSB, telnetOptNAWS}
+	for _, v := range []int{width, height} {
+		hi, lo := byte(v>>8), byte(v)
+		reply = append(reply, hi)
+		if hi == telnetIAC {
+			reply = append(reply, telnetIAC)
+		}
+		reply = append(reply, lo)
+		if lo == telnetIAC {
+			reply = append(reply, telnetIAC)
+		}
+	}
+	return append(reply, telnetIAC, telnetSE)
+}
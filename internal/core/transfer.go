@@ -0,0 +1,507 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Transfer protocol, spoken over any established connection:
+//
+//	sender   -> magic, header frame {name, size, mode, dir}
+//	receiver -> reply frame {offset}          (resume point, 0 for directories)
+//	sender   -> data chunks [len][bytes]..., zero-length chunk, SHA-256 of the whole payload
+//	receiver -> result frame {ok, error}
+//
+// Frames are a big-endian uint32 length followed by JSON. Directories are
+// sent as an uncompressed tar stream built on the fly (size -1).
+
+const transferMagic = "NCFT1"
+
+const (
+	maxFrameSize = 64 * 1024
+	chunkSize    = 32 * 1024
+)
+
+type transferHeader struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Mode uint32 `json:"mode"`
+	Dir  bool   `json:"dir,omitempty"`
+}
+
+type transferReply struct {
+	Offset int64  `json:"offset"`
+	Error  string `json:"error,omitempty"`
+}
+
+type transferResult struct {
+	OK     bool   `json:"ok"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func SendFile(conn io.ReadWriter, path string, showProgress bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	header := transferHeader{
+		Name: filepath.Base(filepath.Clean(path)),
+		Size: info.Size(),
+		Mode: uint32(info.Mode().Perm()),
+		Dir:  info.IsDir(),
+	}
+	if header.Dir {
+		header.Size = -1
+	}
+
+	if _, err := io.WriteString(conn, transferMagic); err != nil {
+		return err
+	}
+	if err := writeFrame(conn, header); err != nil {
+		return err
+	}
+
+	var reply transferReply
+	if err := readFrame(conn, &reply); err != nil {
+		return fmt.Errorf("failed to read reply: %w", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("receiver refused transfer: %s", reply.Error)
+	}
+	if reply.Offset < 0 || (!header.Dir && reply.Offset > header.Size) {
+		return fmt.Errorf("receiver requested invalid offset %d", reply.Offset)
+	}
+
+	hasher := sha256.New()
+	chunks := &chunkWriter{w: conn}
+	var sink io.Writer = io.MultiWriter(chunks, hasher)
+
+	var progress *Progress
+	if showProgress {
+		progress = NewProgress("send "+header.Name, header.Size, reply.Offset)
+		sink = io.MultiWriter(sink, progress)
+	}
+
+	if header.Dir {
+		err = writeTar(sink, path)
+	} else {
+		err = sendRegular(sink, hasher, path, reply.Offset)
+	}
+	if progress != nil {
+		progress.Finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := chunks.Close(); err != nil {
+		return err
+	}
+	if _, err := conn.Write(hasher.Sum(nil)); err != nil {
+		return err
+	}
+
+	var result transferResult
+	if err := readFrame(conn, &result); err != nil {
+		return fmt.Errorf("failed to read result: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("receiver reported failure: %s", result.Error)
+	}
+	return nil
+}
+
+func sendRegular(sink io.Writer, hasher io.Writer, path string, offset int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The receiver already holds the first offset bytes; they still count
+	// towards the checksum.
+	if offset > 0 {
+		if _, err := io.CopyN(hasher, f, offset); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(sink, f)
+	return err
+}
+
+func writeTar(w io.Writer, root string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(filepath.Clean(root))
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ReceiveFile accepts one transfer into dir and returns the path written.
+func ReceiveFile(conn io.ReadWriter, dir string, showProgress bool) (string, error) {
+	magic := make([]byte, len(transferMagic))
+	if _, err := io.ReadFull(conn, magic); err != nil {
+		return "", fmt.Errorf("failed to read transfer header: %w", err)
+	}
+	if string(magic) != transferMagic {
+		return "", errors.New("peer is not sending a file transfer")
+	}
+
+	var header transferHeader
+	if err := readFrame(conn, &header); err != nil {
+		return "", fmt.Errorf("failed to read transfer header: %w", err)
+	}
+
+	name := filepath.Base(filepath.Clean("/" + header.Name))
+	if name == "/" || name == "." || name == ".." {
+		writeFrame(conn, transferReply{Error: "invalid file name"})
+		return "", fmt.Errorf("invalid file name %q", header.Name)
+	}
+	target := filepath.Join(dir, name)
+	partial := target + ".part"
+
+	var offset int64
+	if !header.Dir {
+		if info, err := os.Stat(partial); err == nil && info.Size() <= header.Size {
+			offset = info.Size()
+		}
+	}
+
+	if err := writeFrame(conn, transferReply{Offset: offset}); err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	var data io.Reader = io.TeeReader(&chunkReader{r: conn}, hasher)
+
+	var progress *Progress
+	if showProgress {
+		progress = NewProgress("recv "+name, header.Size, offset)
+		data = io.TeeReader(data, progress)
+	}
+
+	// Directories are extracted aside and moved into place only once the
+	// checksum matches.
+	var staging string
+	if header.Dir {
+		var err error
+		if staging, err = os.MkdirTemp(dir, "."+name+".part-"); err != nil {
+			writeFrame(conn, transferResult{Error: err.Error()})
+			return "", err
+		}
+		defer os.RemoveAll(staging)
+	}
+
+	var err error
+	if header.Dir {
+		err = extractTar(data, staging)
+	} else {
+		err = receiveRegular(data, hasher, partial, offset, header.Size)
+	}
+	if progress != nil {
+		progress.Finish()
+	}
+	if err != nil {
+		writeFrame(conn, transferResult{Error: err.Error()})
+		return "", err
+	}
+
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, sum); err != nil {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	if !bytes.Equal(sum, hasher.Sum(nil)) {
+		if !header.Dir {
+			os.Remove(partial)
+		}
+		err := errors.New("SHA-256 mismatch")
+		writeFrame(conn, transferResult{Error: err.Error()})
+		return "", err
+	}
+
+	if header.Dir {
+		if err := moveTree(filepath.Join(staging, name), target); err != nil {
+			writeFrame(conn, transferResult{Error: err.Error()})
+			return "", err
+		}
+	} else {
+		if err := os.Rename(partial, target); err != nil {
+			writeFrame(conn, transferResult{Error: err.Error()})
+			return "", err
+		}
+		os.Chmod(target, os.FileMode(header.Mode).Perm())
+	}
+
+	if err := writeFrame(conn, transferResult{OK: true, SHA256: hex.EncodeToString(sum)}); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+func receiveRegular(data io.Reader, hasher io.Writer, partial string, offset, size int64) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(partial, flags, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if offset > 0 {
+		existing, err := os.Open(partial)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(hasher, existing, offset)
+		existing.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := io.Copy(f, data)
+	if err != nil {
+		return err
+	}
+	if offset+n != size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", size, offset+n)
+	}
+	return nil
+}
+
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if target != root && !strings.HasPrefix(target, root+string(os.PathSeparator)) {
+			return fmt.Errorf("refusing to extract %q outside %s", hdr.Name, dir)
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || strings.HasPrefix(filepath.Clean(hdr.Linkname), "..") {
+				continue
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drain the tar padding so the chunk terminator is consumed.
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// moveTree moves the tree at src to dst, merging it into dst if that
+// directory already exists.
+func moveTree(src, dst string) error {
+	if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(src, dst)
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			os.Remove(target)
+		}
+		return os.Rename(path, target)
+	})
+}
+
+func writeFrame(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	_, err = w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader, v any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return fmt.Errorf("frame too large (%d bytes)", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type chunkWriter struct {
+	w io.Writer
+}
+
+func (c *chunkWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := len(b)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		if _, err := c.w.Write(size[:]); err != nil {
+			return written, err
+		}
+		if _, err := c.w.Write(b[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (c *chunkWriter) Close() error {
+	_, err := c.w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+type chunkReader struct {
+	r         io.Reader
+	remaining uint32
+	done      bool
+}
+
+func (c *chunkReader) Read(b []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(c.r, size[:]); err != nil {
+			return 0, err
+		}
+		c.remaining = binary.BigEndian.Uint32(size[:])
+		if c.remaining == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		if c.remaining > chunkSize {
+			return 0, fmt.Errorf("chunk too large (%d bytes)", c.remaining)
+		}
+	}
+
+	if uint32(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	n, err := c.r.Read(b)
+	c.remaining -= uint32(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultUDPIdleTimeout is how long a UDP peer may stay silent before its
+// session is closed. Handlers never see an error on UDP otherwise.
+const defaultUDPIdleTimeout = 2 * time.Minute
+
+// udpListener turns a PacketConn into a net.Listener by demultiplexing
+// datagrams per remote address, so UDP peers can be served with the same
+// execute/shell/relay handlers as stream connections.
+type udpListener struct {
+	pc        net.PacketConn
+	idle      time.Duration
+	mu        sync.Mutex
+	sessions  map[string]*udpSession
+	accept    chan *udpSession
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newUDPListener(pc net.PacketConn, idle time.Duration) *udpListener {
+	if idle <= 0 {
+		idle = defaultUDPIdleTimeout
+	}
+	l := &udpListener{
+		pc:       pc,
+		idle:     idle,
+		sessions: make(map[string]*udpSession),
+		accept:   make(chan *udpSession),
+		closed:   make(chan struct{}),
+	}
+	go l.readLoop()
+	go l.expireLoop()
+	return l
+}
+
+func (l *udpListener) readLoop() {
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := l.pc.ReadFrom(buffer)
+		if err != nil {
+			l.Close()
+			return
+		}
+
+		data := append([]byte(nil), buffer[:n]...)
+
+		l.mu.Lock()
+		session, exists := l.sessions[addr.String()]
+		if !exists {
+			session = newUDPSession(l, addr)
+			l.sessions[addr.String()] = session
+		}
+		l.mu.Unlock()
+
+		if !exists {
+			select {
+			case l.accept <- session:
+			case <-l.closed:
+				return
+			}
+		}
+		session.deliver(data)
+	}
+}
+
+// expireLoop closes sessions that have seen no traffic for the idle
+// timeout, which ends their handlers and frees their map entries.
+func (l *udpListener) expireLoop() {
+	ticker := time.NewTicker(max(l.idle/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.closed:
+			return
+		case <-ticker.C:
+		}
+
+		var idle []*udpSession
+		l.mu.Lock()
+		for _, session := range l.sessions {
+			if session.idleFor() >= l.idle {
+				idle = append(idle, session)
+			}
+		}
+		l.mu.Unlock()
+
+		for _, session := range idle {
+			session.Close()
+		}
+	}
+}
+
+func (l *udpListener) Accept() (net.Conn, error) {
+	select {
+	case session := <-l.accept:
+		return session, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *udpListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.pc.Close()
+	})
+	return err
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.pc.LocalAddr()
+}
+
+func (l *udpListener) remove(addr net.Addr) {
+	l.mu.Lock()
+	delete(l.sessions, addr.String())
+	l.mu.Unlock()
+}
+
+type udpSession struct {
+	listener *udpListener
+	remote   net.Addr
+	packets  chan []byte
+	pending  []byte
+	closed   chan struct{}
+	once     sync.Once
+	active   atomic.Int64 // unix nanoseconds of the last datagram either way
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func newUDPSession(l *udpListener, remote net.Addr) *udpSession {
+	u := &udpSession{
+		listener: l,
+		remote:   remote,
+		packets:  make(chan []byte, 64),
+		closed:   make(chan struct{}),
+	}
+	u.touch()
+	return u
+}
+
+func (u *udpSession) touch() {
+	u.active.Store(time.Now().UnixNano())
+}
+
+func (u *udpSession) idleFor() time.Duration {
+	return time.Since(time.Unix(0, u.active.Load()))
+}
+
+func (u *udpSession) deliver(data []byte) {
+	u.touch()
+	select {
+	case u.packets <- data:
+	case <-u.closed:
+	default:
+		// Reader is too slow; drop like the network would.
+	}
+}
+
+func (u *udpSession) Read(b []byte) (int, error) {
+	if len(u.pending) > 0 {
+		n := copy(b, u.pending)
+		u.pending = u.pending[n:]
+		return n, nil
+	}
+
+	var timeout <-chan time.Time
+	u.mu.Lock()
+	deadline := u.deadline
+	u.mu.Unlock()
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case data := <-u.packets:
+		n := copy(b, data)
+		u.pending = data[n:]
+		return n, nil
+	case <-u.closed:
+		return 0, net.ErrClosed
+	case <-u.listener.closed:
+		return 0, net.ErrClosed
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (u *udpSession) Write(b []byte) (int, error) {
+	select {
+	case <-u.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	u.touch()
+	return u.listener.pc.WriteTo(b, u.remote)
+}
+
+func (u *udpSession) Close() error {
+	u.once.Do(func() {
+		close(u.closed)
+		u.listener.remove(u.remote)
+	})
+	return nil
+}
+
+func (u *udpSession) LocalAddr() net.Addr  { return u.listener.pc.LocalAddr() }
+func (u *udpSession) RemoteAddr() net.Addr { return u.remote }
+
+func (u *udpSession) SetDeadline(t time.Time) error {
+	return u.SetReadDeadline(t)
+}
+
+func (u *udpSession) SetReadDeadline(t time.Time) error {
+	u.mu.Lock()
+	u.deadline = t
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *udpSession) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type WaitConfig struct {
+	Any         bool
+	Interval    time.Duration
+	MaxInterval time.Duration
+	DialTimeout time.Duration
+	Banner      *regexp.Regexp
+	HTTPStatus  int
+	HTTPPath    string
+}
+
+type WaitEvent struct {
+	Target  string
+	Ready   bool
+	Attempt int
+	Err     error
+	Elapsed time.Duration
+}
+
+// WaitFor polls every host:port target with exponential backoff until all
+// of them (or any, with config.Any) are ready, or ctx expires. Each attempt
+// is reported through report, which may be nil. A malformed target fails
+// before any attempt is made.
+func WaitFor(ctx context.Context, targets []string, config WaitConfig, report func(WaitEvent)) error {
+	if config.Interval <= 0 {
+		config.Interval = 500 * time.Millisecond
+	}
+	if config.MaxInterval < config.Interval {
+		config.MaxInterval = config.Interval
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if report == nil {
+		report = func(WaitEvent) {}
+	}
+	for _, target := range targets {
+		if _, _, err := ParseWaitTarget(target); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	pending := make(map[string]bool)
+	for _, target := range targets {
+		pending[target] = true
+	}
+
+	ready := make(chan string, len(targets))
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			if waitForTarget(ctx, target, config, func(e WaitEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				report(e)
+			}) {
+				ready <- target
+			}
+		}(target)
+	}
+
+	remaining := len(targets)
+	for remaining > 0 {
+		select {
+		case target := <-ready:
+			mu.Lock()
+			delete(pending, target)
+			mu.Unlock()
+			remaining--
+			if config.Any {
+				return nil
+			}
+		case <-ctx.Done():
+			cancel()
+			wg.Wait()
+			mu.Lock()
+			defer mu.Unlock()
+			var names []string
+			for _, target := range targets {
+				if pending[target] {
+					names = append(names, target)
+				}
+			}
+			return fmt.Errorf("timed out waiting for %s", strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+func waitForTarget(ctx context.Context, target string, config WaitConfig, report func(WaitEvent)) bool {
+	start := time.Now()
+	delay := config.Interval
+
+	for attempt := 1; ; attempt++ {
+		err := checkTarget(ctx, target, config)
+		report(WaitEvent{Target: target, Ready: err == nil, Attempt: attempt, Err: err, Elapsed: time.Since(start)})
+		if err == nil {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > config.MaxInterval {
+			delay = config.MaxInterval
+		}
+	}
+}
+
+// ParseWaitTarget splits a host:port wait target.
+func ParseWaitTarget(target string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in %q", target)
+	}
+	return host, port, nil
+}
+
+// checkTarget makes one attempt at target, abandoned when ctx is done so
+// a run never outlasts its timeout.
+func checkTarget(ctx context.Context, target string, config WaitConfig) error {
+	host, port, err := ParseWaitTarget(target)
+	if err != nil {
+		return err
+	}
+
+	seconds := int((config.DialTimeout + time.Second - 1) / time.Second)
+	client := NewClient(host, port, false, seconds)
+
+	if config.Banner == nil && config.HTTPStatus == 0 {
+		return client.TestConnectionContext(ctx)
+	}
+
+	conn, err := client.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetDeadline(time.Now().Add(config.DialTimeout))
+
+	if config.HTTPStatus != 0 {
+		return checkHTTP(conn, host, config)
+	}
+	return checkBanner(conn, config.Banner)
+}
+
+func checkBanner(conn net.Conn, pattern *regexp.Regexp) error {
+	var banner []byte
+	buffer := make([]byte, 1024)
+	for len(banner) < 16*1024 {
+		n, err := conn.Read(buffer)
+		banner = append(banner, buffer[:n]...)
+		if pattern.Match(banner) {
+			return nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	return fmt.Errorf("banner did not match %s", pattern)
+}
+
+func checkHTTP(conn net.Conn, host string, config WaitConfig) error {
+	path := config.HTTPPath
+	if path == "" {
+		path = "/"
+	}
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: ncCmdExe\r\nConnection: close\r\n\r\n", path, host)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != config.HTTPStatus {
+		return errors.New("unexpected HTTP status " + resp.Status)
+	}
+	return nil
+}
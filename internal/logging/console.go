@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ConsoleHandler prints the message styled by level followed by dimmed
+// key=value fields, in the look the CLI has always had.
+type ConsoleHandler struct {
+	out        io.Writer
+	mu         *sync.Mutex
+	level      slog.Leveler
+	timestamps bool
+	attrs      []slog.Attr
+	group      string
+
+	debugStyle, infoStyle, warnStyle, errorStyle, fieldStyle lipgloss.Style
+}
+
+func NewConsoleHandler(out io.Writer, level slog.Leveler, timestamps bool) *ConsoleHandler {
+	r := lipgloss.NewRenderer(out)
+	return &ConsoleHandler{
+		out:        out,
+		mu:         &sync.Mutex{},
+		level:      level,
+		timestamps: timestamps,
+		debugStyle: r.NewStyle().Foreground(lipgloss.Color("#626262")),
+		infoStyle:  r.NewStyle().Foreground(lipgloss.Color("#00FF00")).Bold(true),
+		warnStyle:  r.NewStyle().Foreground(lipgloss.Color("#FFA500")).Bold(true),
+		errorStyle: r.NewStyle().Foreground(lipgloss.Color("#FF4444")).Bold(true),
+		fieldStyle: r.NewStyle().Foreground(lipgloss.Color("#87CEEB")),
+	}
+}
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var b bytes.Buffer
+
+	if h.timestamps && !record.Time.IsZero() {
+		b.WriteString(record.Time.Format("2006/01/02 15:04:05 "))
+	}
+
+	style := h.infoStyle
+	switch {
+	case record.Level >= slog.LevelError:
+		style = h.errorStyle
+		b.WriteString(style.Render("ERROR") + " ")
+	case record.Level >= slog.LevelWarn:
+		style = h.warnStyle
+		b.WriteString(style.Render("WARN") + " ")
+	case record.Level < slog.LevelInfo:
+		style = h.debugStyle
+	}
+	b.WriteString(style.Render(record.Message))
+
+	var fields []string
+	for _, attr := range h.attrs {
+		fields = appendAttr(fields, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.group, attr)
+		return true
+	})
+	if len(fields) > 0 {
+		b.WriteString(" " + h.fieldStyle.Render(strings.Join(fields, " ")))
+	}
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(b.Bytes())
+	return err
+}
+
+func appendAttr(fields []string, group string, attr slog.Attr) []string {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			fields = appendAttr(fields, key, a)
+		}
+		return fields
+	}
+	return append(fields, key+"="+formatValue(attr.Value))
+}
+
+func formatValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindDuration:
+		s = v.Duration().Round(time.Microsecond).String()
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(v.Any())
+	}
+	if s == "" || strings.ContainsAny(s, " \t\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+	return &clone
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	if clone.group != "" {
+		name = clone.group + "." + name
+	}
+	clone.group = name
+	return &clone
+}
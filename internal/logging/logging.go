@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+type Options struct {
+	Level      slog.Level
+	Format     string    // "text" (styled console), "json" or "logfmt"
+	Output     io.Writer // used when File is empty; defaults to stderr
+	File       string
+	MaxSize    int64 // rotate File after this many bytes, 0 never
+	MaxBackups int
+}
+
+// New builds a logger from opts. The returned closer releases the log file,
+// if any, and is never nil.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	var closer io.Closer = nopCloser{}
+
+	if opts.File != "" {
+		file, err := OpenRotatingFile(opts.File, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	switch opts.Format {
+	case "", "text":
+		handler = NewConsoleHandler(out, opts.Level, opts.File != "")
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	case "logfmt":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (want text, json or logfmt)", opts.Format)
+	}
+
+	return slog.New(handler), closer, nil
+}
+
+// Level maps the --verbose and --quiet flags to a level.
+func Level(verbose, quiet bool) slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelWarn
+	case verbose:
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
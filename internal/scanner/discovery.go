@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// discover reports how host was found to be up, such as "arp", "icmp",
+// "tcp/443" or "ack/80", or "" if nothing answered. All methods run at
+// once and the first answer wins. TCP pings go through the raw socket
+// when there is one, and ACK pings need it.
+func (s *Scanner) discover(host string) string {
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		s.logger.Debug("failed to resolve host", "host", host, "error", err)
+		return ""
+	}
+	ip := addr.IP
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	type method struct {
+		name  string
+		probe func(context.Context) bool
+	}
+	var methods []method
+	if ip.To4() != nil {
+		if onLocalSubnet(ip) {
+			methods = append(methods, method{"arp", func(ctx context.Context) bool { return arpPing(ctx, ip) }})
+		}
+		methods = append(methods, method{"icmp", func(ctx context.Context) bool { return icmpPing(ctx, ip) }})
+	}
+	raw := s.syn != nil && ip.To4() != nil
+	for _, port := range s.pingPorts {
+		if !raw {
+			methods = append(methods, method{fmt.Sprintf("tcp/%d", port), func(ctx context.Context) bool {
+				return tcpPing(ctx, ip, port)
+			}})
+			continue
+		}
+		for _, pingType := range s.pingTypes {
+			name, flags := fmt.Sprintf("tcp/%d", port), byte(tcpSYN)
+			if pingType == "ack" {
+				name, flags = fmt.Sprintf("ack/%d", port), tcpACK
+			}
+			methods = append(methods, method{name, func(ctx context.Context) bool {
+				return s.syn.ping(ctx, ip, port, flags)
+			}})
+		}
+	}
+
+	found := make(chan string, len(methods))
+	for _, m := range methods {
+		s.limiter.wait()
+		go func() {
+			if m.probe(ctx) {
+				found <- m.name
+			} else {
+				found <- ""
+			}
+		}()
+	}
+
+	for range methods {
+		if name := <-found; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// tcpPing connects to port, for when there is no raw socket. An
+// accepted connection and a RST both show the host is up.
+func tcpPing(ctx context.Context, ip net.IP, port int) bool {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	if err == nil {
+		conn.Close()
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func onLocalSubnet(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok && !network.IP.IsLoopback() && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
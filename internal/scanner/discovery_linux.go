@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// icmpPing sends an ICMP echo request over an unprivileged ping socket,
+// allowed when net.ipv4.ping_group_range covers the user, or a raw
+// socket when running as root.
+func icmpPing(ctx context.Context, ip net.IP) bool {
+	raw := false
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	if err != nil {
+		if fd, err = syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP); err != nil {
+			return false
+		}
+		raw = true
+	}
+	defer syscall.Close(fd)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(3 * time.Second)
+	}
+	tv := syscall.NsecToTimeval(time.Until(deadline).Nanoseconds())
+	syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+
+	// The kernel sets the identifier on ping sockets; raw sockets see
+	// every reply, so it is checked there.
+	id, seq := uint16(rand.Intn(0x10000)), uint16(rand.Intn(0x10000))
+	echo := make([]byte, 16)
+	echo[0] = 8 // echo request
+	binary.BigEndian.PutUint16(echo[4:6], id)
+	binary.BigEndian.PutUint16(echo[6:8], seq)
+	copy(echo[8:], "ncCmdExe")
+	binary.BigEndian.PutUint16(echo[2:4], checksum(echo))
+
+	dst := &syscall.SockaddrInet4{}
+	copy(dst.Addr[:], ip.To4())
+	if err := syscall.Sendto(fd, echo, 0, dst); err != nil {
+		return false
+	}
+
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		n, from, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return false
+		}
+		if src, ok := from.(*syscall.SockaddrInet4); !ok || src.Addr != dst.Addr {
+			continue
+		}
+
+		reply := buf[:n]
+		if raw && len(reply) > 0 {
+			reply = reply[int(reply[0]&0x0f)*4:]
+		}
+		if len(reply) >= 8 && reply[0] == 0 && binary.BigEndian.Uint16(reply[6:8]) == seq &&
+			(!raw || binary.BigEndian.Uint16(reply[4:6]) == id) {
+			return true
+		}
+	}
+	return false
+}
+
+// arpPing has the kernel resolve ip, by sending it a datagram, and then
+// waits for a complete entry in the ARP table.
+func arpPing(ctx context.Context, ip net.IP) bool {
+	conn, err := net.Dial("udp4", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return false
+	}
+	conn.Write([]byte{0})
+	conn.Close()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if arpResolved(ip) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// arpResolved looks ip up in /proc/net/arp, whose lines read
+// "IP address, HW type, Flags, HW address, Mask, Device".
+func arpResolved(ip net.IP) bool {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	lines := bufio.NewScanner(f)
+	lines.Scan() // header
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		if len(fields) < 4 || !ip.Equal(net.ParseIP(fields[0])) {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err == nil && flags&0x2 != 0 && fields[3] != "00:00:00:00:00:00" {
+			return true
+		}
+	}
+	return false
+}
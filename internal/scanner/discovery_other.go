@@ -0,0 +1,19 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"context"
+	"net"
+)
+
+// ICMP and ARP discovery need Linux; elsewhere hosts are found by TCP
+// pings alone.
+
+func icmpPing(ctx context.Context, ip net.IP) bool {
+	return false
+}
+
+func arpPing(ctx context.Context, ip net.IP) bool {
+	return false
+}
@@ -0,0 +1,261 @@
+package scanner
+
+import (
+	"iter"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// hostGroupSize is how many hosts are scanned side by side. Their
+	// ports are interleaved so one slow host doesn't hold up the pool.
+	hostGroupSize = 64
+
+	// fdReserve is how many file descriptors are left for everything but
+	// probes.
+	fdReserve = 32
+)
+
+type hostState int
+
+const (
+	hostUnknown hostState = iota
+	hostDiscovering
+	hostUp
+	hostDown
+)
+
+// hostScan tracks one host while its ports are in the job stream.
+type hostScan struct {
+	host   string
+	timing *hostTiming
+	start  time.Time
+
+	mu        sync.Mutex
+	state     hostState
+	discovery string
+	next      int          // index of the next port to hand out
+	remaining int          // ports not yet scanned
+	results   []ScanResult // open and filtered ports only
+	closed    int
+}
+
+type scanJob struct {
+	host     *hostScan
+	port     int
+	discover bool
+}
+
+// run scans ports on every host with one pool of workers fed by a single
+// job stream, calling done once per host as it finishes. Memory is
+// bounded by the host group, however many targets hosts yields.
+func (s *Scanner) run(hosts iter.Seq[string], ports []int, done func(*HostScanResult)) {
+	jobs := make(chan scanJob)
+	wake := make(chan struct{}, 1)
+	var doneMu sync.Mutex
+	finish := func(result *HostScanResult) {
+		doneMu.Lock()
+		defer doneMu.Unlock()
+		done(result)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				s.runJob(job, ports, finish)
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	next, stop := iter.Pull(hosts)
+	defer stop()
+
+	var active []*hostScan
+	exhausted := false
+	for {
+		for !exhausted && len(active) < hostGroupSize {
+			host, ok := next()
+			if !ok {
+				exhausted = true
+				break
+			}
+			active = append(active, s.newHostScan(host, len(ports)))
+		}
+		if len(active) == 0 {
+			break
+		}
+
+		sent := false
+		kept := active[:0]
+		for _, hs := range active {
+			job, ok, more := hs.nextJob(ports)
+			if ok {
+				jobs <- job
+				sent = true
+			}
+			if more {
+				kept = append(kept, hs)
+			}
+		}
+		clear(active[len(kept):])
+		active = kept
+
+		if !sent && len(active) > 0 {
+			// every host is waiting on discovery or a full window
+			<-wake
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+func (s *Scanner) newHostScan(host string, ports int) *hostScan {
+	if s.verbose {
+		s.logger.Info("starting scan", "host", host)
+	}
+	hs := &hostScan{
+		host:      host,
+		timing:    s.newHostTiming(),
+		start:     time.Now(),
+		remaining: ports,
+	}
+	if s.skipHostDomain {
+		hs.state = hostUp
+		hs.discovery = "skipped"
+	}
+	return hs
+}
+
+// nextJob returns the host's next job if it can be sent now, and whether
+// the host has jobs left to hand out.
+func (hs *hostScan) nextJob(ports []int) (scanJob, bool, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	switch hs.state {
+	case hostUnknown:
+		hs.state = hostDiscovering
+		return scanJob{host: hs, discover: true}, true, true
+	case hostDiscovering:
+		return scanJob{}, false, true
+	case hostDown:
+		return scanJob{}, false, false
+	}
+
+	if hs.next >= len(ports) {
+		return scanJob{}, false, false
+	}
+	if !hs.timing.tryAcquire() {
+		return scanJob{}, false, true
+	}
+	job := scanJob{host: hs, port: ports[hs.next]}
+	hs.next++
+	return job, true, hs.next < len(ports)
+}
+
+func (s *Scanner) runJob(job scanJob, ports []int, finish func(*HostScanResult)) {
+	hs := job.host
+
+	if job.discover {
+		method := s.discover(hs.host)
+		hs.mu.Lock()
+		hs.state = hostDown
+		if method != "" {
+			hs.state = hostUp
+			hs.discovery = method
+		}
+		hs.mu.Unlock()
+
+		if method == "" {
+			if s.verbose {
+				s.logger.Info("host is down", "host", hs.host)
+			}
+			finish(&HostScanResult{
+				Host:      hs.host,
+				IsAlive:   false,
+				Timestamp: time.Now(),
+				ScanTime:  time.Since(hs.start),
+			})
+		}
+		return
+	}
+
+	result := s.scanPort(hs.host, job.port, hs.timing)
+	hs.timing.release()
+	if s.verbose {
+		s.logger.Info("port scanned", "host", hs.host, "port", job.port, "protocol", result.Protocol, "state", result.State)
+	}
+
+	hs.mu.Lock()
+	if result.Open || result.Filtered {
+		hs.results = append(hs.results, result)
+	} else {
+		hs.closed++
+	}
+	hs.remaining--
+	last := hs.remaining == 0
+	hs.mu.Unlock()
+
+	if last {
+		finish(s.hostResult(hs))
+	}
+}
+
+func (s *Scanner) scanPort(host string, port int, timing *hostTiming) ScanResult {
+	switch s.scanType {
+	case UDPScan:
+		return s.scanUDPPort(host, port, timing)
+	case SYNScan:
+		return s.scanSYNPort(host, port, timing)
+	}
+	return s.scanTCPPort(host, port, timing)
+}
+
+func (s *Scanner) hostResult(hs *hostScan) *HostScanResult {
+	result := &HostScanResult{
+		Host:      hs.host,
+		IsAlive:   true,
+		Discovery: hs.discovery,
+		Closed:    hs.closed,
+		Timestamp: time.Now(),
+		ScanTime:  time.Since(hs.start),
+	}
+
+	for _, r := range hs.results {
+		if r.Open {
+			result.OpenPorts = append(result.OpenPorts, r)
+		} else {
+			result.FilteredPorts = append(result.FilteredPorts, r)
+		}
+	}
+	for _, list := range [][]ScanResult{result.OpenPorts, result.FilteredPorts} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Port < list[j].Port })
+	}
+
+	if len(result.OpenPorts) > 0 {
+		result.OS = s.detectOS(hs.host, result.OpenPorts)
+	}
+
+	s.logger.Debug("scan finished", "host", hs.host, "open", len(result.OpenPorts), "duration", result.ScanTime)
+	return result
+}
+
+// workerCount bounds concurrency by MaxWorkers and by the open file limit,
+// as every probe in flight may hold a socket.
+func (s *Scanner) workerCount() int {
+	n := s.maxWorkers
+	if limit := maxOpenFiles(); limit > 0 && limit-fdReserve < n {
+		n = max(1, limit-fdReserve)
+		s.logger.Debug("limiting scan concurrency to the open file limit", "workers", n, "limit", limit)
+	}
+	return n
+}
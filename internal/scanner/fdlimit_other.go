@@ -0,0 +1,7 @@
+//go:build !unix
+
+package scanner
+
+func maxOpenFiles() int {
+	return 0
+}
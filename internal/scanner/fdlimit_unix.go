@@ -0,0 +1,17 @@
+//go:build unix
+
+package scanner
+
+import "syscall"
+
+// maxOpenFiles returns the soft limit on open files, or 0 if unknown.
+func maxOpenFiles() int {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0
+	}
+	if limit.Cur > 1<<20 {
+		return 1 << 20
+	}
+	return int(limit.Cur)
+}
@@ -1,13 +1,15 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	//"runtime"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -27,9 +29,6 @@ var (
 	infoStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#87CEEB"))
 
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF4444"))
-
 	warningStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FFA500"))
 )
@@ -44,34 +43,55 @@ const (
 )
 
 type Scanner struct {
-	timeout        time.Duration
-	verbose        bool
-	version        bool
-	maxWorkers     int
-	scanType       ScanType
-	userAgent      string
-	skipHostDomain bool
-	outputFormat   string
-	rateLimit      time.Duration
-	retries        int
-	proxyURL       string
+	timeout          time.Duration
+	verbose          bool
+	version          bool
+	maxWorkers       int
+	scanType         ScanType
+	userAgent        string
+	skipHostDomain   bool
+	outputFormat     string
+	limiter          *rateLimiter
+	hostRateLimit    time.Duration
+	retries          int
+	proxyURL         string
+	topPorts         int
+	excludePorts     string
+	pingPorts        []int
+	pingTypes        []string
+	timing           TimingTemplate
+	logger           *slog.Logger
+	syn              *synScanner
+	probes           []*ServiceProbe
+	versionIntensity int
 }
 
 type ScannerConfig struct {
-	Timeout        time.Duration
-	Verbose        bool
-	Version        bool
-	MaxWorkers     int
-	ScanType       ScanType
-	UserAgent      string
-	SKipHostDomain bool
-	OutputFormat   string
-	RateLimit      time.Duration
-	Retries        int
-	ProxyURL       string
+	Timeout           time.Duration
+	Verbose           bool
+	Version           bool
+	MaxWorkers        int
+	ScanType          ScanType
+	UserAgent         string
+	SKipHostDomain    bool
+	OutputFormat      string
+	RateLimit         time.Duration // average gap between probes across the scan
+	HostRateLimit     time.Duration // average gap between probes to one host
+	Retries           int           // initial retransmissions, raised up to Timing.MaxRetries on loss
+	ProxyURL          string
+	TopPorts          int
+	ExcludePorts      string
+	PingPorts         []int          // TCP ports probed to find hosts, default 80 and 443
+	PingTypes         []string       // "syn" and/or "ack" pings to PingPorts, default syn; ack needs root
+	Timing            TimingTemplate // defaults to the "normal" template
+	VersionIntensity  int            // 1-9, default 7, or VersionIntensityZero; probes rarer than this are skipped unless registered for the port
+	ServiceProbesFile string         // extra probes and matches in nmap-service-probes format
+	Logger            *slog.Logger
 }
 
-func New(config ScannerConfig) *Scanner {
+// New creates a Scanner. It fails only if config names service probes
+// that can't be loaded.
+func New(config ScannerConfig) (*Scanner, error) {
 	if config.Timeout == 0 {
 		config.Timeout = 3 * time.Second
 	}
@@ -84,20 +104,81 @@ func New(config ScannerConfig) *Scanner {
 	if config.Retries == 0 {
 		config.Retries = 1
 	}
+	if config.PingPorts == nil {
+		config.PingPorts = []int{80, 443}
+	}
+	if config.PingTypes == nil {
+		config.PingTypes = []string{"syn"}
+	}
+	if config.Timing.MaxRTTTimeout == 0 {
+		config.Timing = TimingTemplates[3]
+	}
+	switch {
+	case config.VersionIntensity == 0:
+		config.VersionIntensity = defaultVersionIntensity
+	case config.VersionIntensity == VersionIntensityZero:
+		config.VersionIntensity = 0
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	var probes []*ServiceProbe
+	if config.Version {
+		var err error
+		if probes, err = loadServiceProbes(config.ServiceProbesFile); err != nil {
+			return nil, fmt.Errorf("service probes: %w", err)
+		}
+	}
+
+	// The raw socket serves SYN scans and TCP pings; without it pings
+	// fall back to connect().
+	var syn *synScanner
+	if config.ScanType == SYNScan || !config.SKipHostDomain {
+		var err error
+		syn, err = newSYNScanner()
+		switch {
+		case err == nil:
+		case config.ScanType == SYNScan:
+			config.Logger.Warn("SYN scan unavailable, falling back to connect scan", "error", err)
+			config.ScanType = TCPScan
+		case slices.Contains(config.PingTypes, "ack"):
+			config.Logger.Warn("ACK ping unavailable, using connect pings", "error", err)
+		}
+	}
 
 	return &Scanner{
-		timeout:        config.Timeout,
-		verbose:        config.Verbose,
-		version:        config.Version,
-		maxWorkers:     config.MaxWorkers,
-		scanType:       config.ScanType,
-		userAgent:      config.UserAgent,
-		skipHostDomain: config.SKipHostDomain,
-		outputFormat:   config.OutputFormat,
-		rateLimit:      config.RateLimit,
-		retries:        config.Retries,
-		proxyURL:       config.ProxyURL,
+		timeout:          config.Timeout,
+		verbose:          config.Verbose,
+		version:          config.Version,
+		maxWorkers:       config.MaxWorkers,
+		scanType:         config.ScanType,
+		userAgent:        config.UserAgent,
+		skipHostDomain:   config.SKipHostDomain,
+		outputFormat:     config.OutputFormat,
+		limiter:          newRateLimiter(config.RateLimit),
+		hostRateLimit:    config.HostRateLimit,
+		retries:          config.Retries,
+		proxyURL:         config.ProxyURL,
+		topPorts:         config.TopPorts,
+		excludePorts:     config.ExcludePorts,
+		pingPorts:        config.PingPorts,
+		pingTypes:        config.PingTypes,
+		timing:           config.Timing,
+		logger:           config.Logger,
+		syn:              syn,
+		probes:           probes,
+		versionIntensity: config.VersionIntensity,
+	}, nil
+}
+
+// Close releases the raw socket held for SYN scans and TCP pings. The
+// Scanner must not be used afterwards.
+func (s *Scanner) Close() error {
+	if s.syn == nil {
+		return nil
 	}
+	return s.syn.Close()
 }
 
 type ScanResult struct {
@@ -105,7 +186,10 @@ type ScanResult struct {
 	Port            int               `json:"port"`
 	Protocol        string            `json:"protocol"`
 	Service         string            `json:"service"`
+	Product         string            `json:"product,omitempty"`
 	Version         string            `json:"version,omitempty"`
+	ExtraInfo       string            `json:"extra_info,omitempty"`
+	CPE             []string          `json:"cpe,omitempty"`
 	Banner          string            `json:"banner,omitempty"`
 	Open            bool              `json:"open"`
 	Filtered        bool              `json:"filtered,omitempty"`
@@ -139,8 +223,9 @@ type HTTPInfo struct {
 type HostScanResult struct {
 	Host          string        `json:"host"`
 	IsAlive       bool          `json:"is_alive"`
+	Discovery     string        `json:"discovery,omitempty"` // how the host was found up: arp, icmp, tcp/PORT, ack/PORT or skipped
 	OpenPorts     []ScanResult  `json:"open_ports"`
-	ClosedPorts   []ScanResult  `json:"closed_ports,omitempty"`
+	Closed        int           `json:"closed"` // closed ports are counted, not kept
 	FilteredPorts []ScanResult  `json:"filtered_ports,omitempty"`
 	ScanTime      time.Duration `json:"scan_time"`
 	Timestamp     time.Time     `json:"timestamp"`
@@ -155,225 +240,195 @@ type OSInfo struct {
 }
 
 func (s *Scanner) ScanHost(host, portRange string) (*HostScanResult, error) {
-	start := time.Now()
-
-	if s.verbose {
-		fmt.Println(scanStyle.Render(fmt.Sprintf("Starting scan of  %s.....", host)))
-	}
-
-	if !s.skipHostDomain && !s.isHostAlive(host) {
-		if s.verbose {
-			fmt.Println(warningStyle.Render(fmt.Sprintf("Host %s is down", host)))
-		}
-		return &HostScanResult{
-			Host:      host,
-			IsAlive:   false,
-			Timestamp: time.Now(),
-			ScanTime:  time.Since(start),
-		}, nil
-	}
 	ports, err := s.parsePorts(portRange)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse port range: %w", err)
+		return nil, fmt.Errorf("invalid port specification: %w", err)
 	}
 
-	resilts := s.scanPorts(host, ports)
-
-	hostResult := &HostScanResult{
-		Host:      host,
-		IsAlive:   true,
-		Timestamp: time.Now(),
-		ScanTime:  time.Since(start),
-	}
-
-	for _, result := range resilts {
-		if result.Open {
-			hostResult.OpenPorts = append(hostResult.OpenPorts, result)
-		} else if result.Filtered {
-			hostResult.FilteredPorts = append(hostResult.FilteredPorts, result)
-		} else {
-			hostResult.ClosedPorts = append(hostResult.ClosedPorts, result)
-		}
-	}
-
-	sort.Slice(hostResult.OpenPorts, func(i, j int) bool {
-		return hostResult.OpenPorts[i].Port < hostResult.OpenPorts[j].Port
+	var result *HostScanResult
+	s.run(func(yield func(string) bool) { yield(host) }, ports, func(r *HostScanResult) {
+		result = r
+		s.displayResults(r)
 	})
-
-	if len(hostResult.OpenPorts) > 0 {
-		hostResult.OS = s.detectOS(host, hostResult.OpenPorts)
-	}
-
-	s.displayResults(hostResult)
-	return hostResult, nil
+	return result, nil
 }
 
+// ScanRange scans every host in ipRange, which takes any target form
+// understood by ParseTargets.
 func (s *Scanner) ScanRange(ipRange, portRange string) ([]*HostScanResult, error) {
-	fmt.Println(scanStyle.Render(fmt.Sprintf("Scanning range %s...", ipRange)))
-
-	ips, err := parseIPRange(ipRange)
+	targets, err := ParseTargets(TargetSpec{Targets: []string{ipRange}})
 	if err != nil {
 		return nil, err
 	}
+	return s.ScanTargets(targets, portRange)
+}
 
+func (s *Scanner) ScanTargets(targets *Targets, portRange string) ([]*HostScanResult, error) {
 	var results []*HostScanResult
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	sem := make(chan struct{}, s.maxWorkers)
-
-	for _, ip := range ips {
-		wg.Add(1)
-		go func(host string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			res, err := s.ScanHost(host, portRange)
-			if err == nil {
-				mu.Lock()
-				results = append(results, res)
-				mu.Unlock()
-			} else if s.verbose {
-				fmt.Println(errorStyle.Render(fmt.Sprintf("Error scannig host %s: %v", host, err)))
-			}
-		}(ip)
-	}
+	err := s.ScanTargetsFunc(targets, portRange, func(r *HostScanResult) {
+		results = append(results, r)
+	})
+	return results, err
+}
 
-	wg.Wait()
-	return results, nil
+// ScanTargetsFunc scans hosts as targets generates them, displaying each
+// host's result and passing it to fn, if not nil, instead of keeping it.
+// Use it for large scans.
+func (s *Scanner) ScanTargetsFunc(targets *Targets, portRange string, fn func(*HostScanResult)) error {
+	ports, err := s.parsePorts(portRange)
+	if err != nil {
+		return fmt.Errorf("invalid port specification: %w", err)
+	}
 
+	s.run(targets.All(), ports, func(r *HostScanResult) {
+		s.displayResults(r)
+		if fn != nil {
+			fn(r)
+		}
+	})
+	return nil
 }
 
+// parsePorts resolves the ports to scan for the scan's protocol from
+// portRange, --top-ports and --exclude-ports. With top ports an empty
+// portRange means all ports.
 func (s *Scanner) parsePorts(portRange string) ([]int, error) {
-	var ports []int
-
-	if strings.Contains(portRange, "-") {
-		parts := strings.Split(portRange, "-")
-		if len(parts) == 2 {
-			start, _ := strconv.Atoi(parts[0])
-			end, _ := strconv.Atoi(parts[1])
-			for i := start; i <= end; i++ {
-				ports = append(ports, i)
-			}
-		}
-	} else if strings.Contains(portRange, ",") {
-		parts := strings.Split(portRange, ",")
-		for _, part := range parts {
-			if port, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
-				ports = append(ports, port)
-			}
-		}
-	} else {
-		if port, err := strconv.Atoi(portRange); err == nil {
-			ports = append(ports, port)
+	if portRange == "" && s.topPorts > 0 {
+		portRange = "-"
+	}
+	spec, err := ParsePortSpec(portRange)
+	if err != nil {
+		return nil, err
+	}
+
+	var exclude *PortSpec
+	if s.excludePorts != "" {
+		if exclude, err = ParsePortSpec(s.excludePorts); err != nil {
+			return nil, fmt.Errorf("exclusions: %w", err)
 		}
 	}
 
+	protocol := s.protocol()
+	if protocol == "udp" && len(spec.TCP) > 0 {
+		s.logger.Warn("TCP ports in the port specification are skipped by a UDP scan", "ports", len(spec.TCP))
+	} else if protocol == "tcp" && len(spec.UDP) > 0 {
+		s.logger.Warn("UDP ports in the port specification are skipped by a TCP scan", "ports", len(spec.UDP))
+	}
+
+	ports := spec.Ports(protocol, exclude)
+	if s.topPorts > 0 {
+		ports = TopPorts(protocol, s.topPorts, ports)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no %s ports to scan", strings.ToUpper(protocol))
+	}
 	return ports, nil
 }
 
-func (s *Scanner) scanPorts(host string, ports []int) []ScanResult {
-	var results []ScanResult
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	sem := make(chan struct{}, s.maxWorkers)
-
-	for _, port := range ports {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			open := s.isPortOpen(host, p)
-
-			result := ScanResult{
-				Host:         host,
-				Port:         p,
-				Protocol:     "tcp",
-				Service:      s.getServiceName(p),
-				Open:         open,
-				State:        "closed",
-				ResponseTime: s.timeout,
-				Timestamp:    time.Now(),
-			}
+func (s *Scanner) protocol() string {
+	if s.scanType == UDPScan {
+		return "udp"
+	}
+	return "tcp"
+}
 
-			if open {
-				result.State = "open"
-				if s.version {
-					result.Version = s.detectVersion(host, p)
-				}
-			}
+func (s *Scanner) scanTCPPort(host string, port int, timing *hostTiming) ScanResult {
+	state, rtt := s.portState(host, port, timing)
 
-			mu.Lock()
-			results = append(results, result)
-			mu.Unlock()
-
-			if s.verbose {
-				stateStyle := closedStyle
-				symbol := "X"
-				if open {
-					stateStyle = openStyle
-					symbol = "✔️"
-				}
-				fmt.Printf("%s %d/tcp\n", stateStyle.Render(symbol), p)
-			}
-		}(port)
+	result := ScanResult{
+		Host:         host,
+		Port:         port,
+		Protocol:     "tcp",
+		Service:      s.getServiceName(port),
+		Open:         state == "open",
+		Filtered:     state == "filtered" || state == "unreachable",
+		State:        state,
+		ResponseTime: rtt,
+		Timestamp:    time.Now(),
 	}
 
-	wg.Wait()
-	return results
+	if result.Open && s.version {
+		s.detectVersion(&result)
+	}
+	return result
 }
 
-func (s *Scanner) isPortOpen(host string, port int) bool {
-	timeout := time.Duration(s.timeout) * time.Second
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), timeout)
-	if err != nil {
-		return false
+// portState connects to port and classifies the outcome: a RST means
+// closed, an ICMP host or network unreachable means unreachable, and a
+// timeout or administrative block means filtered. Timeouts are retried
+// as the host's timing allows.
+func (s *Scanner) portState(host string, port int, timing *hostTiming) (string, time.Duration) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	for attempt := 0; ; attempt++ {
+		timing.send()
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", addr, timing.timeout())
+		rtt := time.Since(start)
+		if err == nil {
+			conn.Close()
+			timing.observe(rtt, attempt > 0)
+			return "open", rtt
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() && attempt < timing.maxRetries() {
+			continue
+		}
+		state := dialErrorState(err)
+		if state != "filtered" {
+			timing.observe(rtt, attempt > 0)
+		}
+		return state, rtt
 	}
-	conn.Close()
-	return true
 }
 
-func (s *Scanner) isHostAlive(host string) bool {
-	timeout := time.Duration(s.timeout) * time.Second
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s", host), timeout)
-	if err != nil {
-		return false
+func dialErrorState(err error) string {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "closed"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
 	}
-	conn.Close()
-	return true
+	return "filtered"
 }
 
 func (s *Scanner) getServiceName(port int) string {
-	services := map[int]string{
-		21:   "ftp",
-		22:   "ssh",
-		23:   "telnet",
-		25:   "smtp",
-		53:   "dns",
-		80:   "http",
-		110:  "pop3",
-		135:  "msrpc",
-		139:  "netbios-ssn",
-		143:  "imap",
-		443:  "https",
-		993:  "imaps",
-		995:  "pop3s",
-		1433: "ms-sql-s",
-		1521: "oracle",
-		3306: "mysql",
-		3389: "ms-wbt-server",
-		5432: "postgresql",
-		5900: "vnc",
-		6379: "redis",
-		8080: "http-proxy",
-		9200: "elasticsearch",
-	}
-
-	if service, exists := services[port]; exists {
+	return ServiceName(port)
+}
+
+var wellKnownServices = map[int]string{
+	21:   "ftp",
+	22:   "ssh",
+	23:   "telnet",
+	25:   "smtp",
+	53:   "dns",
+	69:   "tftp",
+	80:   "http",
+	110:  "pop3",
+	123:  "ntp",
+	135:  "msrpc",
+	137:  "netbios-ns",
+	139:  "netbios-ssn",
+	143:  "imap",
+	161:  "snmp",
+	443:  "https",
+	993:  "imaps",
+	995:  "pop3s",
+	1433: "ms-sql-s",
+	1521: "oracle",
+	1900: "upnp",
+	3306: "mysql",
+	3389: "ms-wbt-server",
+	5353: "mdns",
+	5432: "postgresql",
+	5900: "vnc",
+	6379: "redis",
+	8080: "http-proxy",
+	9200: "elasticsearch",
+}
+
+func ServiceName(port int) string {
+	if service, exists := wellKnownServices[port]; exists {
 		return service
 	}
 	return "unknown"
@@ -384,67 +439,14 @@ func (s *Scanner) ScanHostWithResults(host, portRange string) []ScanResult {
 	if err != nil {
 		return nil
 	}
-	var results []ScanResult
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	sem := make(chan struct{}, 50)
-
-	for _, port := range ports {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			if s.isPortOpen(host, p) {
-				service := s.getServiceName(p)
-				version := ""
-				if s.version {
-					version = s.detectVersion(host, p)
-				}
-
-				mu.Lock()
-				results = append(results, ScanResult{
-					Port:    p,
-					Service: service,
-					Version: version,
-					Open:    true,
-				})
-				mu.Unlock()
-			}
-		}(port)
-	}
 
-	wg.Wait()
+	var results []ScanResult
+	s.run(func(yield func(string) bool) { yield(host) }, ports, func(r *HostScanResult) {
+		results = r.OpenPorts
+	})
 	return results
 }
 
-func (s *Scanner) detectVersion(host string, port int) string {
-	timeout := time.Duration(s.timeout) * time.Second
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), timeout)
-	if err != nil {
-		return ""
-	}
-
-	defer conn.Close()
-
-	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		return ""
-	}
-
-	banner := strings.TrimSpace(string(buffer[:n]))
-	if len(banner) > 50 {
-		banner = banner[:50] + "..."
-	}
-
-	return fmt.Sprintf("(%s)", banner)
-}
-
 /*func (s *Scanner) detectOS(host string) string {
 	os := runtime.GOOS
     switch os {
@@ -490,43 +492,15 @@ func (s *Scanner) detectOS(host string, openPorts []ScanResult) *OSInfo {
 	}
 }
 
-func parseIPRange(ipRange string) ([]string, error) {
-	parts := strings.Split(ipRange, "-")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid IP range: expected format 'startIP-endIP'")
-	}
-
-	startIP := net.ParseIP(parts[0]).To4()
-	endIP := net.ParseIP(parts[1]).To4()
-	if startIP == nil || endIP == nil {
-		return nil, fmt.Errorf("invalid IP address in range")
-	}
-
-	var ips []string
-	for ip := startIP; !ip.Equal(endIP); ip = nextIP(ip) {
-		ips = append(ips, ip.String())
-	}
-	ips = append(ips, endIP.String())
-	return ips, nil
-}
-
-func nextIP(ip net.IP) net.IP {
-	ip = ip.To4()
-	result := make(net.IP, len(ip))
-	copy(result, ip)
-	for i := len(result) - 1; i >= 0; i-- {
-		result[i]++
-		if result[i] != 0 {
-			break
-		}
-	}
-	return result
-}
-
 func (s *Scanner) displayResults(result *HostScanResult) {
 	fmt.Println()
 	fmt.Println(scanStyle.Render(fmt.Sprintf("Scan Results for %s", result.Host)))
 	fmt.Println(infoStyle.Render(fmt.Sprintf("Scan Time: %v", result.ScanTime)))
+	if !result.IsAlive {
+		fmt.Println(warningStyle.Render("Host seems down (use -Pn to scan it anyway)"))
+	} else if result.Discovery != "" && result.Discovery != "skipped" {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Host is up (%s)", result.Discovery)))
+	}
 	if result.OS != nil {
 		fmt.Println(warningStyle.Render(fmt.Sprintf("OS Detected: %s (%s) - Confidence: %.2f",
 			result.OS.Name, result.OS.Version, result.OS.Confidence)))
@@ -538,16 +512,25 @@ func (s *Scanner) displayResults(result *HostScanResult) {
 		}
 		fmt.Println(style.Render(fmt.Sprintf("\n[%s]", label)))
 		for _, port := range ports {
+			service := port.Service
+			if port.State == "unreachable" || port.State == "open|filtered" {
+				service += " (" + port.State + ")"
+			}
+			if version := port.ServiceVersion(); version != "" {
+				service += " " + version
+			}
 			fmt.Printf("%s %d/%s %s\n",
 				style.Render("•"),
 				port.Port,
 				port.Protocol,
-				infoStyle.Render(port.Service))
+				infoStyle.Render(service))
 		}
 	}
 
 	printPorts(result.OpenPorts, "Open Ports", openStyle)
-	printPorts(result.ClosedPorts, "Closed Ports", closedStyle)
+	if result.Closed > 0 {
+		fmt.Println(closedStyle.Render(fmt.Sprintf("\nNot shown: %d closed ports", result.Closed)))
+	}
 	printPorts(result.FilteredPorts, "Filtered Ports", warningStyle)
 
 	fmt.Println()
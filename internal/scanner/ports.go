@@ -0,0 +1,206 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PortSpec is a parsed nmap-style port specification such as
+// "22,80,1000-2000,U:53,T:443". A "T:" or "U:" prefix applies to the
+// ports that follow it until the next prefix; unqualified ports apply to
+// every protocol.
+type PortSpec struct {
+	Any []int
+	TCP []int
+	UDP []int
+}
+
+// ParsePortSpec accepts comma-separated ports, ranges ("1000-2000"),
+// open-ended ranges ("-1024", "60000-"), "-" for every port and service
+// names ("http,ssh").
+func ParsePortSpec(spec string) (*PortSpec, error) {
+	parsed := &PortSpec{}
+	target := &parsed.Any
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty port specification")
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if prefix, rest, ok := strings.Cut(item, ":"); ok {
+			switch strings.ToUpper(prefix) {
+			case "T":
+				target = &parsed.TCP
+			case "U":
+				target = &parsed.UDP
+			default:
+				return nil, fmt.Errorf("unknown protocol qualifier %q in %q", prefix, item)
+			}
+			item = strings.TrimSpace(rest)
+		}
+		if item == "" {
+			return nil, fmt.Errorf("empty entry in port specification %q", spec)
+		}
+
+		start, end, err := parsePortItem(item)
+		if err != nil {
+			return nil, err
+		}
+		for p := start; p <= end; p++ {
+			*target = append(*target, p)
+		}
+	}
+	return parsed, nil
+}
+
+func parsePortItem(item string) (int, int, error) {
+	if item == "-" {
+		return 1, 65535, nil
+	}
+
+	if (item[0] < '0' || item[0] > '9') && item[0] != '-' {
+		port, ok := ServicePort(item)
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown service name %q", item)
+		}
+		return port, port, nil
+	}
+
+	startStr, endStr, isRange := strings.Cut(item, "-")
+	if !isRange {
+		port, err := parsePortNumber(item)
+		return port, port, err
+	}
+
+	start, end := 1, 65535
+	var err error
+	if startStr != "" {
+		if start, err = parsePortNumber(startStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if endStr != "" {
+		if end, err = parsePortNumber(endStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid port range %q: start is after end", item)
+	}
+	return start, end, nil
+}
+
+func parsePortNumber(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of range 1-65535", port)
+	}
+	return port, nil
+}
+
+// Ports returns the sorted, de-duplicated ports to scan for protocol
+// ("tcp" or "udp"), minus any in exclude. Both may be nil.
+func (p *PortSpec) Ports(protocol string, exclude *PortSpec) []int {
+	excluded := make(map[int]bool)
+	if exclude != nil {
+		for _, port := range exclude.forProtocol(protocol) {
+			excluded[port] = true
+		}
+	}
+
+	seen := make(map[int]bool)
+	var ports []int
+	for _, port := range p.forProtocol(protocol) {
+		if !seen[port] && !excluded[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+func (p *PortSpec) forProtocol(protocol string) []int {
+	if p == nil {
+		return nil
+	}
+	ports := append([]int(nil), p.Any...)
+	if protocol == "udp" {
+		return append(ports, p.UDP...)
+	}
+	return append(ports, p.TCP...)
+}
+
+// ServicePort looks up the port of a well-known service name.
+func ServicePort(name string) (int, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for port, service := range wellKnownServices {
+		if service == name {
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+// Ports ordered by how often they are found open, most common first
+// (from nmap's frequency data).
+var (
+	topTCPPorts = []int{
+		80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+		1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+		26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+		2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+		7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+	}
+
+	topUDPPorts = []int{
+		631, 161, 137, 123, 138, 1434, 445, 135, 67, 53, 139, 500, 68, 520, 1900, 4500, 514, 49152, 162, 69,
+		5353, 111, 49154, 1701, 998, 996, 997, 999, 3283, 49153, 1812, 136, 2222, 2049, 32768, 5060, 1025, 1433, 3456, 80,
+		20031, 1026, 7, 1646, 1645, 593, 518, 2048, 31337, 515,
+	}
+)
+
+// TopPorts returns the n most common ports for protocol among candidates,
+// or among all ports when candidates is nil. Past the frequency table,
+// remaining candidates are taken in ascending order.
+func TopPorts(protocol string, n int, candidates []int) []int {
+	ranked := topTCPPorts
+	if protocol == "udp" {
+		ranked = topUDPPorts
+	}
+
+	allowed := func(int) bool { return true }
+	if candidates != nil {
+		set := make(map[int]bool, len(candidates))
+		for _, p := range candidates {
+			set[p] = true
+		}
+		allowed = func(p int) bool { return set[p] }
+	}
+
+	taken := make(map[int]bool)
+	var ports []int
+	for _, p := range ranked {
+		if len(ports) == n {
+			break
+		}
+		if allowed(p) {
+			ports = append(ports, p)
+			taken[p] = true
+		}
+	}
+	for p := 1; p <= 65535 && len(ports) < n; p++ {
+		if !taken[p] && allowed(p) {
+			ports = append(ports, p)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
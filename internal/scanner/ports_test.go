@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec             string
+		exclude          string
+		wantTCP, wantUDP []int
+	}{
+		{spec: "22", wantTCP: []int{22}, wantUDP: []int{22}},
+		{spec: "80,22, 443,80", wantTCP: []int{22, 80, 443}, wantUDP: []int{22, 80, 443}},
+		{spec: "1000-1003", wantTCP: []int{1000, 1001, 1002, 1003}, wantUDP: []int{1000, 1001, 1002, 1003}},
+		{spec: "-3", wantTCP: []int{1, 2, 3}, wantUDP: []int{1, 2, 3}},
+		{spec: "65533-", wantTCP: []int{65533, 65534, 65535}, wantUDP: []int{65533, 65534, 65535}},
+		{spec: "http,SSH", wantTCP: []int{22, 80}, wantUDP: []int{22, 80}},
+		{spec: "U:53,161,T:22,80", wantTCP: []int{22, 80}, wantUDP: []int{53, 161}},
+		{spec: "25,t:443,u:53", wantTCP: []int{25, 443}, wantUDP: []int{25, 53}},
+		{spec: "T:http", wantTCP: []int{80}, wantUDP: nil},
+		{spec: "20-25", exclude: "21,23-24", wantTCP: []int{20, 22, 25}, wantUDP: []int{20, 22, 25}},
+		{spec: "T:53,U:53", exclude: "U:53", wantTCP: []int{53}, wantUDP: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := ParsePortSpec(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var exclude *PortSpec
+			if tt.exclude != "" {
+				if exclude, err = ParsePortSpec(tt.exclude); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := spec.Ports("tcp", exclude); !reflect.DeepEqual(got, tt.wantTCP) {
+				t.Errorf("tcp ports = %v, want %v", got, tt.wantTCP)
+			}
+			if got := spec.Ports("udp", exclude); !reflect.DeepEqual(got, tt.wantUDP) {
+				t.Errorf("udp ports = %v, want %v", got, tt.wantUDP)
+			}
+		})
+	}
+}
+
+func TestParsePortSpecAll(t *testing.T) {
+	spec, err := ParsePortSpec("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := spec.Ports("tcp", nil)
+	if len(ports) != 65535 || ports[0] != 1 || ports[len(ports)-1] != 65535 {
+		t.Errorf("got %d ports from %d to %d, want 1-65535", len(ports), ports[0], ports[len(ports)-1])
+	}
+}
+
+func TestParsePortSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "empty port specification"},
+		{"22,,80", "empty entry"},
+		{"T:", "empty entry"},
+		{"S:80", `unknown protocol qualifier "S"`},
+		{"0", "out of range"},
+		{"65536", "out of range"},
+		{"100-50", "start is after end"},
+		{"80-x", `invalid port "x"`},
+		{"nosuchservice", `unknown service name "nosuchservice"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParsePortSpec(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	tests := []struct {
+		name       string
+		protocol   string
+		n          int
+		candidates []int
+		want       []int
+	}{
+		{"most common tcp", "tcp", 3, nil, []int{23, 80, 443}},
+		{"most common udp", "udp", 3, nil, []int{137, 161, 631}},
+		{"within candidates", "tcp", 2, []int{22, 23, 8080, 9}, []int{22, 23}},
+		{"past the table in port order", "tcp", 3, []int{60001, 60000, 80}, []int{80, 60000, 60001}},
+		{"more than the candidates", "tcp", 10, []int{22}, []int{22}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopPorts(tt.protocol, tt.n, tt.candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopPorts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	all := TopPorts("tcp", len(topTCPPorts)+5, nil)
+	if len(all) != len(topTCPPorts)+5 {
+		t.Errorf("got %d ports, want %d", len(all), len(topTCPPorts)+5)
+	}
+}
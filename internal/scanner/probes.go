@@ -0,0 +1,355 @@
+package scanner
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultServiceProbes is the built-in probe database, in the format of
+// nmap-service-probes.
+//
+//go:embed service-probes
+var defaultServiceProbes string
+
+// ServiceProbe is a payload sent to identify a service, with the patterns
+// that recognise its replies.
+type ServiceProbe struct {
+	Protocol  string // "TCP" or "UDP"
+	Name      string
+	Payload   []byte
+	Ports     map[int]bool // tried whatever the intensity
+	SSLPorts  map[int]bool // the same, over TLS
+	Rarity    int          // 1 (common) to 9 (rare)
+	TotalWait time.Duration
+	Fallback  []string // probes whose matches also apply to replies
+	Matches   []*ServiceMatch
+}
+
+// ServiceMatch recognises a reply. Soft matches name the service but keep
+// probing for its version, using only probes that can match it.
+type ServiceMatch struct {
+	Service  string
+	Pattern  *regexp.Regexp
+	Soft     bool
+	Template map[string]string // p, v, i, h, o, d with $1 style references
+	CPE      []string
+}
+
+// ServiceInfo is what version detection learned about a port.
+type ServiceInfo struct {
+	Name       string
+	Product    string
+	Version    string
+	Info       string
+	Hostname   string
+	OS         string
+	DeviceType string
+	CPE        []string
+	TLS        bool
+	Soft       bool
+}
+
+// ParseServiceProbes reads a probe database in the nmap-service-probes
+// format. Patterns use Go regexp syntax and are matched against replies
+// with each byte as one character, so \xNN matches byte NN.
+func ParseServiceProbes(r io.Reader) ([]*ServiceProbe, error) {
+	var probes []*ServiceProbe
+	var probe *ServiceProbe
+
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		directive, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		if directive != "Probe" && directive != "Exclude" && probe == nil {
+			return nil, fmt.Errorf("line %d: %s before the first Probe", n, directive)
+		}
+
+		var err error
+		switch directive {
+		case "Probe":
+			probe, err = parseProbe(rest)
+			if err == nil {
+				probes = append(probes, probe)
+			}
+		case "match", "softmatch":
+			var m *ServiceMatch
+			if m, err = parseMatch(rest, directive == "softmatch"); err == nil {
+				probe.Matches = append(probe.Matches, m)
+			}
+		case "ports":
+			probe.Ports, err = parsePortSet(rest)
+		case "sslports":
+			probe.SSLPorts, err = parsePortSet(rest)
+		case "rarity":
+			if probe.Rarity, err = strconv.Atoi(rest); err == nil && (probe.Rarity < 1 || probe.Rarity > 9) {
+				err = fmt.Errorf("rarity %d out of range 1-9", probe.Rarity)
+			}
+		case "totalwaitms":
+			var ms int
+			ms, err = strconv.Atoi(rest)
+			probe.TotalWait = time.Duration(ms) * time.Millisecond
+		case "fallback":
+			probe.Fallback = strings.Split(rest, ",")
+		case "Exclude", "tcpwrappedms":
+		default:
+			err = fmt.Errorf("unknown directive %q", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return probes, lines.Err()
+}
+
+// parseProbe reads "TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|".
+func parseProbe(s string) (*ServiceProbe, error) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) < 3 || (fields[0] != "TCP" && fields[0] != "UDP") || !strings.HasPrefix(fields[2], "q") {
+		return nil, fmt.Errorf("invalid Probe %q", s)
+	}
+	payload, _, err := cutDelimited(fields[2][1:])
+	if err != nil {
+		return nil, err
+	}
+	return &ServiceProbe{
+		Protocol:  fields[0],
+		Name:      fields[1],
+		Payload:   []byte(unescape(payload)),
+		Rarity:    1,
+		TotalWait: 5 * time.Second,
+	}, nil
+}
+
+// parseMatch reads "service m|pattern|flags p/product/ v/$1/ cpe:/a:x:y:$1/".
+func parseMatch(s string, soft bool) (*ServiceMatch, error) {
+	service, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "m") {
+		return nil, fmt.Errorf("invalid match %q", s)
+	}
+	pattern, rest, err := cutDelimited(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	flags := ""
+	for rest != "" && (rest[0] == 'i' || rest[0] == 's') {
+		flags += rest[:1]
+		rest = rest[1:]
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(nulEscapes(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+
+	m := &ServiceMatch{Service: service, Pattern: re, Soft: soft, Template: make(map[string]string)}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, skip := rest[:1], 1
+		if strings.HasPrefix(rest, "cpe:") {
+			key, skip = "cpe", len("cpe:")
+		}
+		var value string
+		if value, rest, err = cutDelimited(rest[skip:]); err != nil {
+			return nil, err
+		}
+		// skip flags such as the "a" in cpe:/.../a
+		rest = strings.TrimLeft(rest, "abcdefghijklmnopqrstuvwxyz")
+		if key == "cpe" {
+			m.CPE = append(m.CPE, "cpe:/"+value)
+		} else {
+			m.Template[key] = value
+		}
+	}
+	return m, nil
+}
+
+// nulEscapes rewrites nmap's \0, which Go regexp spells \x00.
+func nulEscapes(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '\\' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		if pattern[i+1] == '0' && (i+2 == len(pattern) || pattern[i+2] < '0' || pattern[i+2] > '7') {
+			b.WriteString(`\x00`)
+		} else {
+			b.WriteString(pattern[i : i+2])
+		}
+		i++
+	}
+	return b.String()
+}
+
+// cutDelimited splits "|text|rest" into text and rest, using whatever
+// character comes first as the delimiter.
+func cutDelimited(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing delimiter")
+	}
+	end := strings.IndexByte(s[1:], s[0])
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated %q", s)
+	}
+	return s[1 : end+1], s[end+2:], nil
+}
+
+// unescape expands the C escapes used in probe payloads.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'x':
+			if i+2 < len(s) {
+				if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte('x')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func parsePortSet(s string) (map[int]bool, error) {
+	spec, err := ParsePortSpec(s)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int]bool)
+	for _, port := range spec.Ports("tcp", nil) {
+		set[port] = true
+	}
+	return set, nil
+}
+
+// mergeServiceProbes adds extra to base. Matches for a probe base already
+// has are tried before its own; new probes are appended.
+func mergeServiceProbes(base, extra []*ServiceProbe) []*ServiceProbe {
+	merged := append([]*ServiceProbe(nil), base...)
+	for _, probe := range extra {
+		if existing := findProbe(merged, probe.Protocol, probe.Name); existing != nil {
+			combined := *existing
+			combined.Matches = append(append([]*ServiceMatch(nil), probe.Matches...), existing.Matches...)
+			for i, p := range merged {
+				if p == existing {
+					merged[i] = &combined
+				}
+			}
+			continue
+		}
+		merged = append(merged, probe)
+	}
+	return merged
+}
+
+func findProbe(probes []*ServiceProbe, protocol, name string) *ServiceProbe {
+	for _, p := range probes {
+		if p.Protocol == protocol && p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// match tries m against a reply, returning the service it describes.
+func (m *ServiceMatch) match(reply string) *ServiceInfo {
+	groups := m.Pattern.FindStringSubmatch(reply)
+	if groups == nil {
+		return nil
+	}
+	info := &ServiceInfo{
+		Name:       m.Service,
+		Product:    expandTemplate(m.Template["p"], groups),
+		Version:    expandTemplate(m.Template["v"], groups),
+		Info:       expandTemplate(m.Template["i"], groups),
+		Hostname:   expandTemplate(m.Template["h"], groups),
+		OS:         expandTemplate(m.Template["o"], groups),
+		DeviceType: expandTemplate(m.Template["d"], groups),
+		Soft:       m.Soft,
+	}
+	for _, cpe := range m.CPE {
+		info.CPE = append(info.CPE, expandTemplate(cpe, groups))
+	}
+	return info
+}
+
+var templateRef = regexp.MustCompile(`\$(\d)|\$P\((\d)\)|\$SUBST\((\d),"([^"]*)","([^"]*)"\)`)
+
+// expandTemplate fills in $1, $P(1) (printable characters only) and
+// $SUBST(1,"from","to") from the match groups.
+func expandTemplate(template string, groups []string) string {
+	group := func(ref string) string {
+		n, _ := strconv.Atoi(ref)
+		if n >= len(groups) {
+			return ""
+		}
+		return fromLatin1(groups[n])
+	}
+	return templateRef.ReplaceAllStringFunc(template, func(ref string) string {
+		parts := templateRef.FindStringSubmatch(ref)
+		switch {
+		case parts[1] != "":
+			return group(parts[1])
+		case parts[2] != "":
+			return strings.Map(func(r rune) rune {
+				if r < 0x20 || r > 0x7e {
+					return -1
+				}
+				return r
+			}, group(parts[2]))
+		}
+		return strings.ReplaceAll(group(parts[3]), parts[4], parts[5])
+	})
+}
+
+// latin1 maps each byte to the character of the same value, so patterns
+// see binary replies byte for byte.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func fromLatin1(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		b = append(b, byte(r))
+	}
+	return string(b)
+}
@@ -0,0 +1,241 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseServiceProbes(t *testing.T) {
+	db := `# comment
+Exclude T:9100
+Probe TCP NULL q||
+totalwaitms 2000
+match ssh m|^SSH-([\d.]+)-| p/generic/ i/protocol $1/
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+rarity 3
+ports 80,8000-8002
+sslports 443
+fallback NULL
+tcpwrappedms 3000
+match http m|^HTTP/1\.1 200|s p/web/ v/1/ cpe:/a:vendor:web:1/a
+softmatch http m|^http/|i
+`
+	probes, err := ParseServiceProbes(strings.NewReader(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probes) != 2 {
+		t.Fatalf("got %d probes, want 2", len(probes))
+	}
+
+	null, get := probes[0], probes[1]
+	if null.Name != "NULL" || len(null.Payload) != 0 || null.TotalWait != 2*time.Second || null.Rarity != 1 {
+		t.Errorf("NULL probe = %+v", null)
+	}
+	if string(get.Payload) != "GET / HTTP/1.0\r\n\r\n" {
+		t.Errorf("payload = %q", get.Payload)
+	}
+	if get.Rarity != 3 || get.TotalWait != 5*time.Second {
+		t.Errorf("rarity %d, wait %v", get.Rarity, get.TotalWait)
+	}
+	wantPorts := map[int]bool{80: true, 8000: true, 8001: true, 8002: true}
+	if !reflect.DeepEqual(get.Ports, wantPorts) || !reflect.DeepEqual(get.SSLPorts, map[int]bool{443: true}) {
+		t.Errorf("ports %v, sslports %v", get.Ports, get.SSLPorts)
+	}
+	if !reflect.DeepEqual(get.Fallback, []string{"NULL"}) {
+		t.Errorf("fallback = %v", get.Fallback)
+	}
+
+	if len(get.Matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(get.Matches))
+	}
+	hard, soft := get.Matches[0], get.Matches[1]
+	if hard.Soft || hard.Service != "http" || hard.Template["p"] != "web" || hard.Template["v"] != "1" {
+		t.Errorf("match = %+v", hard)
+	}
+	if !reflect.DeepEqual(hard.CPE, []string{"cpe:/a:vendor:web:1"}) {
+		t.Errorf("cpe = %v", hard.CPE)
+	}
+	if !soft.Soft || soft.match("HTTP/1.0 404") == nil {
+		t.Errorf("case-insensitive softmatch = %+v", soft)
+	}
+}
+
+func TestParseServiceProbesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		db   string
+		want string
+	}{
+		{"match before probe", "match x m|x|\n", "line 1: match before the first Probe"},
+		{"unknown directive", "Probe TCP NULL q||\nbogus 1\n", `line 2: unknown directive "bogus"`},
+		{"bad protocol", "Probe SCTP NULL q||\n", "line 1: invalid Probe"},
+		{"unterminated payload", "Probe TCP X q|abc\n", "line 1: unterminated"},
+		{"rarity range", "Probe TCP X q||\nrarity 10\n", "line 2: rarity 10 out of range 1-9"},
+		{"bad regexp", "Probe TCP X q||\nmatch x m|(|\n", "line 2: service x: error parsing regexp"},
+		{"bad ports", "Probe TCP X q||\nports 70000\n", "line 2:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseServiceProbes(strings.NewReader(tt.db))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinServiceProbes(t *testing.T) {
+	probes := builtinServiceProbes()
+	if len(probes) == 0 || probes[0].Name != "NULL" {
+		t.Fatalf("built-in database should start with the NULL probe")
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`GET / HTTP/1.0\r\n\r\n`, "GET / HTTP/1.0\r\n\r\n"},
+		{`\0\0\0\x08\x04\xd2`, "\x00\x00\x00\x08\x04\xd2"},
+		{`\a\t\\\|`, "\a\t\\|"},
+		{`\xZZ`, "xZZ"},
+		{`\x4`, "x4"},
+		{`trailing\`, `trailing\`},
+	}
+	for _, tt := range tests {
+		if got := unescape(tt.in); got != tt.want {
+			t.Errorf("unescape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNulEscapes(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`\0`, `\x00`},
+		{`\0\0`, `\x00\x00`},
+		{`a\0b`, `a\x00b`},
+		{`\01`, `\01`},
+		{`\\0`, `\\0`},
+		{`\d\0`, `\d\x00`},
+	}
+	for _, tt := range tests {
+		if got := nulEscapes(tt.in); got != tt.want {
+			t.Errorf("nulEscapes(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	groups := []string{"whole", "8.9p1", "Ubuntu\x01\x02-3", "a_b_c"}
+	tests := []struct {
+		template, want string
+	}{
+		{"$1", "8.9p1"},
+		{"v$1 ($3)", "v8.9p1 (a_b_c)"},
+		{"$P(2)", "Ubuntu-3"},
+		{`$SUBST(3,"_",".")`, "a.b.c"},
+		{"$9", ""},
+		{"no refs", "no refs"},
+	}
+	for _, tt := range tests {
+		if got := expandTemplate(tt.template, groups); got != tt.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestServiceMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		reply string
+		want  *ServiceInfo
+	}{
+		{
+			name:  "ssh",
+			line:  `ssh m|^SSH-([\d.]+)-OpenSSH_([\w.]+)\r?\n| p/OpenSSH/ v/$2/ i/protocol $1/ cpe:/a:openbsd:openssh:$2/`,
+			reply: "SSH-2.0-OpenSSH_9.6\r\n",
+			want:  &ServiceInfo{Name: "ssh", Product: "OpenSSH", Version: "9.6", Info: "protocol 2.0", CPE: []string{"cpe:/a:openbsd:openssh:9.6"}},
+		},
+		{
+			name:  "mysql greeting matched byte for byte",
+			line:  `mysql m|^.\0\0\0\x0a([\d.]+)[-\w.]*\0|s p/MySQL/ v/$1/`,
+			reply: "\x4a\x00\x00\x00\x0a8.0.36-0ubuntu0\x00\x0b\x00\x00\x00",
+			want:  &ServiceInfo{Name: "mysql", Product: "MySQL", Version: "8.0.36"},
+		},
+		{
+			name:  "high bytes are single characters",
+			line:  `telnet m|^\xff[\xfb-\xfe](.)|s p/telnetd/ i/$1/`,
+			reply: "\xff\xfd\xe9",
+			want:  &ServiceInfo{Name: "telnet", Product: "telnetd", Info: "\xe9"},
+		},
+		{
+			name:  "tls alert",
+			line:  `ssl m|^\x15\x03[\x00-\x04]\x00\x02\x02|s p/TLS/`,
+			reply: "\x15\x03\x03\x00\x02\x02\x46",
+			want:  &ServiceInfo{Name: "ssl", Product: "TLS"},
+		},
+		{
+			name:  "http header across lines",
+			line:  `http m|^HTTP/1\.[01] \d\d\d .*\r\nServer: nginx/([\d.]+)|s p/nginx/ v/$1/`,
+			reply: "HTTP/1.1 200 OK\r\nDate: now\r\nServer: nginx/1.24.0\r\n\r\n",
+			want:  &ServiceInfo{Name: "http", Product: "nginx", Version: "1.24.0"},
+		},
+		{
+			name:  "no match",
+			line:  `ftp m|^220 |`,
+			reply: "SSH-2.0-x\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMatch(tt.line, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := m.match(latin1([]byte(tt.reply)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeServiceProbes(t *testing.T) {
+	parse := func(db string) []*ServiceProbe {
+		probes, err := ParseServiceProbes(strings.NewReader(db))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return probes
+	}
+	base := parse("Probe TCP NULL q||\nmatch a m|a|\nProbe TCP Get q|GET|\nmatch b m|b|\n")
+	extra := parse("Probe TCP NULL q||\nmatch c m|c|\nProbe TCP New q|NEW|\nmatch d m|d|\n")
+
+	merged := mergeServiceProbes(base, extra)
+
+	var names []string
+	for _, p := range merged {
+		names = append(names, p.Name)
+	}
+	if want := []string{"NULL", "Get", "New"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("probes = %v, want %v", names, want)
+	}
+
+	var services []string
+	for _, m := range merged[0].Matches {
+		services = append(services, m.Service)
+	}
+	if want := []string{"c", "a"}; !reflect.DeepEqual(services, want) {
+		t.Errorf("NULL matches = %v, want %v (extra first)", services, want)
+	}
+	if len(base[0].Matches) != 1 {
+		t.Errorf("merging modified the base probes")
+	}
+}
@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"net"
+	"time"
+)
+
+// scanSYNPort half-opens a connection to port. Hosts without an IPv4
+// address are connect scanned instead.
+func (s *Scanner) scanSYNPort(host string, port int, timing *hostTiming) ScanResult {
+	addr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil || addr.IP.To4() == nil {
+		return s.scanTCPPort(host, port, timing)
+	}
+
+	start := time.Now()
+	state := s.syn.probe(addr.IP, port, timing)
+
+	result := ScanResult{
+		Host:         host,
+		Port:         port,
+		Protocol:     "tcp",
+		Service:      s.getServiceName(port),
+		Open:         state == "open",
+		Filtered:     state == "filtered",
+		State:        state,
+		ResponseTime: time.Since(start),
+		Timestamp:    time.Now(),
+	}
+	if result.Open && s.version {
+		s.detectVersion(&result)
+	}
+	return result
+}
@@ -0,0 +1,287 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// synScanner sends SYNs on a raw socket and matches the replies in one
+// capture loop. The kernel answers a SYN/ACK with a RST since no socket
+// owns the source port, so connections are never completed.
+type synScanner struct {
+	fd      int
+	srcPort uint16
+	closing chan struct{}
+	stopped chan struct{}
+	close   sync.Once
+
+	mu      sync.Mutex
+	pending map[synKey]*synProbe
+}
+
+type synKey struct {
+	ip   [4]byte
+	port uint16
+	ack  bool // an ACK probe, which can share ip:port with a SYN
+}
+
+type synProbe struct {
+	seq   uint32
+	ack   uint32 // for an ACK probe, the RST's sequence number
+	flags byte
+	reply chan string
+}
+
+const (
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+)
+
+func newSYNScanner() (*synScanner, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("raw socket: %w (needs root or CAP_NET_RAW)", err)
+	}
+
+	// Closing the fd doesn't wake a blocked recvfrom, so capture polls
+	// for Close.
+	tv := syscall.NsecToTimeval(int64(captureInterval))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("raw socket: %w", err)
+	}
+
+	sc := &synScanner{
+		fd:      fd,
+		srcPort: uint16(40000 + rand.Intn(20000)),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+		pending: make(map[synKey]*synProbe),
+	}
+	go sc.capture()
+	return sc, nil
+}
+
+// captureInterval is how often capture checks for Close while idle.
+const captureInterval = 100 * time.Millisecond
+
+func (sc *synScanner) capture() {
+	defer close(sc.stopped)
+	buf := make([]byte, 65535)
+	for {
+		select {
+		case <-sc.closing:
+			return
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(sc.fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR || err == syscall.EAGAIN {
+				continue
+			}
+			return
+		}
+		sc.handle(buf[:n])
+	}
+}
+
+// Close stops the capture loop and releases the raw socket.
+func (sc *synScanner) Close() error {
+	var err error
+	sc.close.Do(func() {
+		close(sc.closing)
+		<-sc.stopped
+		err = syscall.Close(sc.fd)
+	})
+	return err
+}
+
+func (sc *synScanner) handle(packet []byte) {
+	if len(packet) < 20 || packet[0]>>4 != 4 || packet[9] != syscall.IPPROTO_TCP {
+		return
+	}
+	ihl := int(packet[0]&0x0f) * 4
+	if len(packet) < ihl+20 {
+		return
+	}
+	tcp := packet[ihl:]
+	if binary.BigEndian.Uint16(tcp[2:4]) != sc.srcPort {
+		return
+	}
+
+	var key synKey
+	copy(key.ip[:], packet[12:16])
+	key.port = binary.BigEndian.Uint16(tcp[0:2])
+	seq := binary.BigEndian.Uint32(tcp[4:8])
+	ack := binary.BigEndian.Uint32(tcp[8:12])
+	flags := tcp[13]
+
+	for _, kind := range []bool{false, true} {
+		key.ack = kind
+		sc.mu.Lock()
+		probe := sc.pending[key]
+		sc.mu.Unlock()
+		if probe == nil {
+			continue
+		}
+		if state := probe.answer(seq, ack, flags); state != "" {
+			select {
+			case probe.reply <- state:
+			default:
+			}
+		}
+	}
+}
+
+// answer classifies a reply to the probe, or returns "" if it isn't one.
+// A RST answering an ACK takes its sequence number from our ACK; a reply
+// to a SYN acknowledges its sequence number.
+func (p *synProbe) answer(seq, ack uint32, flags byte) string {
+	switch {
+	case p.flags == tcpACK:
+		if flags&tcpRST != 0 && seq == p.ack {
+			return "closed"
+		}
+	case ack != p.seq+1:
+	case flags&(tcpSYN|tcpACK) == tcpSYN|tcpACK:
+		return "open"
+	case flags&tcpRST != 0:
+		return "closed"
+	}
+	return ""
+}
+
+// probe sends SYNs to ip:port, resending as the host's timing allows, and
+// reports open on a SYN/ACK, closed on a RST and filtered when nothing
+// comes back.
+func (sc *synScanner) probe(ip net.IP, port int, timing *hostTiming) string {
+	src, err := sourceIP(ip, port)
+	if err != nil {
+		return "filtered"
+	}
+
+	probe := &synProbe{seq: rand.Uint32(), flags: tcpSYN, reply: make(chan string, 1)}
+	addr, done := sc.register(ip, port, probe)
+	defer done()
+	packet := sc.packet(src, ip.To4(), uint16(port), probe)
+
+	for attempt := 0; attempt <= timing.maxRetries(); attempt++ {
+		timing.send()
+		start := time.Now()
+		if err := syscall.Sendto(sc.fd, packet, 0, addr); err != nil {
+			return "filtered"
+		}
+		select {
+		case state := <-probe.reply:
+			timing.observe(time.Since(start), attempt > 0)
+			return state
+		case <-time.After(timing.timeout()):
+		}
+	}
+	return "filtered"
+}
+
+// ping sends a SYN or an ACK, as flags says, to ip:port and reports
+// whether the host answered before ctx is done. Any answer to a SYN shows
+// the host is up, as does a RST to an ACK, which firewalls that only
+// block new connections let through.
+func (sc *synScanner) ping(ctx context.Context, ip net.IP, port int, flags byte) bool {
+	src, err := sourceIP(ip, port)
+	if err != nil {
+		return false
+	}
+
+	probe := &synProbe{seq: rand.Uint32(), ack: rand.Uint32(), flags: flags, reply: make(chan string, 1)}
+	addr, done := sc.register(ip, port, probe)
+	defer done()
+
+	if err := syscall.Sendto(sc.fd, sc.packet(src, ip.To4(), uint16(port), probe), 0, addr); err != nil {
+		return false
+	}
+	select {
+	case <-probe.reply:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// register makes probe the one awaiting replies from ip:port until done
+// is called.
+func (sc *synScanner) register(ip net.IP, port int, probe *synProbe) (*syscall.SockaddrInet4, func()) {
+	key := synKey{port: uint16(port), ack: probe.flags == tcpACK}
+	copy(key.ip[:], ip.To4())
+
+	sc.mu.Lock()
+	sc.pending[key] = probe
+	sc.mu.Unlock()
+	return &syscall.SockaddrInet4{Addr: key.ip}, func() {
+		sc.mu.Lock()
+		if sc.pending[key] == probe {
+			delete(sc.pending, key)
+		}
+		sc.mu.Unlock()
+	}
+}
+
+// sourceIP finds the local address the kernel routes to ip from, which
+// the TCP checksum covers. Connecting a UDP socket sends nothing.
+func sourceIP(ip net.IP, port int) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4(), nil
+}
+
+// packet builds the TCP segment for probe. SYNs carry the MSS option.
+func (sc *synScanner) packet(src, dst net.IP, port uint16, probe *synProbe) []byte {
+	size := 20
+	if probe.flags&tcpSYN != 0 {
+		size = 24
+	}
+	tcp := make([]byte, size)
+	binary.BigEndian.PutUint16(tcp[0:2], sc.srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], port)
+	binary.BigEndian.PutUint32(tcp[4:8], probe.seq)
+	if probe.flags&tcpACK != 0 {
+		binary.BigEndian.PutUint32(tcp[8:12], probe.ack)
+	}
+	tcp[12] = byte(size/4) << 4 // data offset
+	tcp[13] = probe.flags
+	binary.BigEndian.PutUint16(tcp[14:16], 1024)
+	if size == 24 {
+		copy(tcp[20:], []byte{2, 4, 0x05, 0xb4}) // MSS 1460
+	}
+
+	pseudo := make([]byte, 12, 12+len(tcp))
+	copy(pseudo[0:4], src)
+	copy(pseudo[4:8], dst)
+	pseudo[9] = syscall.IPPROTO_TCP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(tcp)))
+	binary.BigEndian.PutUint16(tcp[16:18], checksum(append(pseudo, tcp...)))
+	return tcp
+}
+
+func checksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
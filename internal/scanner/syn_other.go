@@ -0,0 +1,32 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+type synScanner struct{}
+
+const (
+	tcpSYN = 0x02
+	tcpACK = 0x10
+)
+
+func newSYNScanner() (*synScanner, error) {
+	return nil, errors.New("SYN scanning is only supported on Linux")
+}
+
+func (sc *synScanner) probe(ip net.IP, port int, timing *hostTiming) string {
+	return "filtered"
+}
+
+func (sc *synScanner) ping(ctx context.Context, ip net.IP, port int, flags byte) bool {
+	return false
+}
+
+func (sc *synScanner) Close() error {
+	return nil
+}
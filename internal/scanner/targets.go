@@ -0,0 +1,497 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TargetSpec lists what to scan. Each target may be an IP, a CIDR
+// ("10.0.0.0/16", "example.com/24"), an octet range ("10.0.1-3.1-254",
+// "192.168.1.*"), a start-end range ("10.0.0.1-10.0.0.50") or a hostname,
+// which expands to all of its A and AAAA records.
+type TargetSpec struct {
+	Targets     []string
+	InputFile   string // one or more targets per line, "-" for stdin
+	Exclude     []string
+	ExcludeFile string
+	Random      bool
+}
+
+// Targets generates scan targets lazily, so large networks never exist
+// in memory as a list.
+type Targets struct {
+	sources   []targetSource
+	inputFile string
+	exclude   []targetSource
+	random    bool
+}
+
+type targetSource interface {
+	each(yield func(netip.Addr) bool) bool
+	contains(addr netip.Addr) bool
+}
+
+// randomBlock is how many targets are shuffled together with Random;
+// ordering is random within each block.
+const randomBlock = 4096
+
+func ParseTargets(spec TargetSpec) (*Targets, error) {
+	t := &Targets{inputFile: spec.InputFile, random: spec.Random}
+
+	for _, target := range splitTargets(spec.Targets) {
+		source, err := parseTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		t.sources = append(t.sources, source)
+	}
+	if len(t.sources) == 0 && t.inputFile == "" {
+		return nil, fmt.Errorf("no targets specified")
+	}
+	if t.inputFile != "" && t.inputFile != "-" {
+		// the file is read lazily; make sure now that it can be
+		if err := checkReadable(t.inputFile); err != nil {
+			return nil, err
+		}
+	}
+
+	excludes := splitTargets(spec.Exclude)
+	if spec.ExcludeFile != "" {
+		data, err := os.ReadFile(spec.ExcludeFile)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, targetFields(string(data))...)
+	}
+	for _, target := range excludes {
+		source, err := parseTarget(target)
+		if err != nil {
+			return nil, fmt.Errorf("exclusion: %w", err)
+		}
+		t.exclude = append(t.exclude, source)
+	}
+	return t, nil
+}
+
+// splitTargets accepts targets separated by whitespace or, outside octet
+// ranges, by commas.
+func splitTargets(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, field := range strings.Fields(v) {
+			if isOctetRange(field) {
+				out = append(out, field)
+				continue
+			}
+			for _, part := range strings.Split(field, ",") {
+				if part != "" {
+					out = append(out, part)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// targetFields splits target file contents, dropping # comments.
+func targetFields(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		out = append(out, strings.Fields(line)...)
+	}
+	return out
+}
+
+// All yields every target address as a string.
+func (t *Targets) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		emit := yield
+		var block []string
+		if t.random {
+			emit = func(host string) bool {
+				block = append(block, host)
+				if len(block) < randomBlock {
+					return true
+				}
+				return flushShuffled(&block, yield)
+			}
+		}
+
+		visit := func(addr netip.Addr) bool {
+			if t.excluded(addr) {
+				return true
+			}
+			return emit(addr.String())
+		}
+
+		for _, source := range t.sources {
+			if !source.each(visit) {
+				return
+			}
+		}
+		if t.inputFile != "" && !t.eachInFile(visit) {
+			return
+		}
+		if t.random {
+			flushShuffled(&block, yield)
+		}
+	}
+}
+
+func flushShuffled(block *[]string, yield func(string) bool) bool {
+	rand.Shuffle(len(*block), func(i, j int) {
+		(*block)[i], (*block)[j] = (*block)[j], (*block)[i]
+	})
+	for _, host := range *block {
+		if !yield(host) {
+			return false
+		}
+	}
+	*block = (*block)[:0]
+	return true
+}
+
+func (t *Targets) excluded(addr netip.Addr) bool {
+	for _, source := range t.exclude {
+		if source.contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		err = fmt.Errorf("%s is a directory", path)
+	}
+	return err
+}
+
+// eachInFile reads the input file one line at a time as it is consumed.
+func (t *Targets) eachInFile(visit func(netip.Addr) bool) bool {
+	var r io.Reader = os.Stdin
+	if t.inputFile != "-" {
+		f, err := os.Open(t.inputFile)
+		if err != nil {
+			slog.Error("failed to open target file", "file", t.inputFile, "error", err)
+			return true
+		}
+		defer f.Close()
+		r = f
+	}
+
+	lines := bufio.NewScanner(r)
+	for n := 1; lines.Scan(); n++ {
+		for _, target := range splitTargets(targetFields(lines.Text())) {
+			source, err := parseTarget(target)
+			if err != nil {
+				slog.Warn("skipping invalid target", "file", t.inputFile, "line", n, "error", err)
+				continue
+			}
+			if !source.each(visit) {
+				return false
+			}
+		}
+	}
+	if err := lines.Err(); err != nil {
+		slog.Error("failed to read target file", "file", t.inputFile, "error", err)
+	}
+	return true
+}
+
+func parseTarget(target string) (targetSource, error) {
+	if addr, err := netip.ParseAddr(target); err == nil {
+		return singleTarget(addr.Unmap()), nil
+	}
+
+	if base, bits, ok := strings.Cut(target, "/"); ok {
+		n, err := strconv.Atoi(bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix length in %q", target)
+		}
+		if addr, err := netip.ParseAddr(base); err == nil {
+			prefix, err := addr.Unmap().Prefix(n)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", target, err)
+			}
+			return prefixTarget(prefix), nil
+		}
+		if !isHostname(base) {
+			return nil, fmt.Errorf("invalid target %q", target)
+		}
+		return &hostnameTarget{name: base, bits: n}, nil
+	}
+
+	if start, end, ok := strings.Cut(target, "-"); ok {
+		first, err1 := netip.ParseAddr(start)
+		last, err2 := netip.ParseAddr(end)
+		if err1 == nil && err2 == nil {
+			first, last = first.Unmap(), last.Unmap()
+			if first.BitLen() != last.BitLen() || last.Less(first) {
+				return nil, fmt.Errorf("invalid address range %q", target)
+			}
+			return addrRange{first: first, last: last}, nil
+		}
+	}
+
+	if isOctetRange(target) {
+		return parseOctetRange(target)
+	}
+
+	if isHostname(target) {
+		return &hostnameTarget{name: target, bits: -1}, nil
+	}
+	return nil, fmt.Errorf("invalid target %q", target)
+}
+
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' && r != '.' }) >= 0 &&
+		strings.ContainsFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' })
+}
+
+type singleTarget netip.Addr
+
+func (s singleTarget) each(yield func(netip.Addr) bool) bool {
+	return yield(netip.Addr(s))
+}
+
+func (s singleTarget) contains(addr netip.Addr) bool {
+	return netip.Addr(s) == addr
+}
+
+type prefixTarget netip.Prefix
+
+func (p prefixTarget) each(yield func(netip.Addr) bool) bool {
+	prefix := netip.Prefix(p).Masked()
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		if !yield(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p prefixTarget) contains(addr netip.Addr) bool {
+	return netip.Prefix(p).Contains(addr)
+}
+
+type addrRange struct {
+	first, last netip.Addr
+}
+
+func (r addrRange) each(yield func(netip.Addr) bool) bool {
+	for addr := r.first; addr.IsValid() && !r.last.Less(addr); addr = addr.Next() {
+		if !yield(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r addrRange) contains(addr netip.Addr) bool {
+	return addr.BitLen() == r.first.BitLen() && !addr.Less(r.first) && !r.last.Less(addr)
+}
+
+// octetRange is an IPv4 pattern where each octet is a list of values and
+// ranges, such as "10.0.1-3.1-254", "192.168.1.*" or "10.0.0,2.1".
+type octetRange [4][]uint8
+
+func isOctetRange(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789-,*") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseOctetRange(target string) (targetSource, error) {
+	var r octetRange
+	for i, part := range strings.Split(target, ".") {
+		for _, item := range strings.Split(part, ",") {
+			low, high, err := parseOctetItem(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid octet %q in %q", item, target)
+			}
+			for v := low; v <= high; v++ {
+				r[i] = append(r[i], uint8(v))
+			}
+		}
+	}
+	return r, nil
+}
+
+func parseOctetItem(item string) (int, int, error) {
+	if item == "*" || item == "-" {
+		return 0, 255, nil
+	}
+	lowStr, highStr, isRange := strings.Cut(item, "-")
+	if !isRange {
+		highStr = lowStr
+	}
+
+	low, high := 0, 255
+	var err error
+	if lowStr != "" {
+		if low, err = strconv.Atoi(lowStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if highStr != "" {
+		if high, err = strconv.Atoi(highStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if low < 0 || high > 255 || low > high {
+		return 0, 0, fmt.Errorf("out of range")
+	}
+	return low, high, nil
+}
+
+func (r octetRange) each(yield func(netip.Addr) bool) bool {
+	for _, a := range r[0] {
+		for _, b := range r[1] {
+			for _, c := range r[2] {
+				for _, d := range r[3] {
+					if !yield(netip.AddrFrom4([4]byte{a, b, c, d})) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (r octetRange) contains(addr netip.Addr) bool {
+	if !addr.Is4() {
+		return false
+	}
+	octets := addr.As4()
+	for i, values := range r {
+		found := false
+		for _, v := range values {
+			if v == octets[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// hostnameTarget resolves when first used. With bits >= 0 it expands to
+// the network of that size around the first IPv4 address; hostnames with
+// only IPv6 addresses are not expanded, as even a short prefix there
+// holds more addresses than could ever be scanned.
+type hostnameTarget struct {
+	name     string
+	bits     int
+	resolved bool
+	addrs    []netip.Addr
+	network  netip.Prefix
+}
+
+func (h *hostnameTarget) resolve() []netip.Addr {
+	if h.resolved {
+		return h.addrs
+	}
+	h.resolved = true
+
+	ips, err := net.LookupIP(h.name)
+	if err != nil {
+		slog.Warn("failed to resolve target", "host", h.name, "error", err)
+		return nil
+	}
+	var addrs []netip.Addr
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	h.setAddrs(addrs)
+	return h.addrs
+}
+
+func (h *hostnameTarget) setAddrs(addrs []netip.Addr) {
+	h.resolved = true
+	h.addrs = addrs
+	if h.bits < 0 {
+		return
+	}
+	for _, addr := range addrs {
+		if !addr.Is4() {
+			continue
+		}
+		network, err := addr.Prefix(h.bits)
+		if err != nil {
+			slog.Warn("invalid network size for target", "host", h.name, "error", err)
+			return
+		}
+		h.network = network
+		return
+	}
+	slog.Warn("no IPv4 address to expand target around", "host", h.name)
+}
+
+func (h *hostnameTarget) each(yield func(netip.Addr) bool) bool {
+	addrs := h.resolve()
+	if h.bits >= 0 {
+		if !h.network.IsValid() {
+			return true
+		}
+		return prefixTarget(h.network).each(yield)
+	}
+
+	for _, addr := range addrs {
+		if !yield(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *hostnameTarget) contains(addr netip.Addr) bool {
+	addrs := h.resolve()
+	if h.bits >= 0 {
+		return h.network.IsValid() && h.network.Contains(addr)
+	}
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package scanner
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		spec TargetSpec
+		want []string
+	}{
+		{
+			name: "single addresses",
+			spec: TargetSpec{Targets: []string{"10.0.0.1", "::1", "::ffff:10.0.0.2"}},
+			want: []string{"10.0.0.1", "::1", "10.0.0.2"},
+		},
+		{
+			name: "cidr",
+			spec: TargetSpec{Targets: []string{"192.168.1.5/30"}},
+			want: []string{"192.168.1.4", "192.168.1.5", "192.168.1.6", "192.168.1.7"},
+		},
+		{
+			name: "ipv6 cidr",
+			spec: TargetSpec{Targets: []string{"2001:db8::/127"}},
+			want: []string{"2001:db8::", "2001:db8::1"},
+		},
+		{
+			name: "start-end range",
+			spec: TargetSpec{Targets: []string{"10.0.0.254-10.0.1.1"}},
+			want: []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"},
+		},
+		{
+			name: "octet ranges and lists",
+			spec: TargetSpec{Targets: []string{"10.0.1-2.1,5"}},
+			want: []string{"10.0.1.1", "10.0.1.5", "10.0.2.1", "10.0.2.5"},
+		},
+		{
+			name: "open octet ranges",
+			spec: TargetSpec{Targets: []string{"10.0.0.-1", "10.0.0.254-"}},
+			want: []string{"10.0.0.0", "10.0.0.1", "10.0.0.254", "10.0.0.255"},
+		},
+		{
+			name: "comma and space separated",
+			spec: TargetSpec{Targets: []string{"10.0.0.1,10.0.0.2 10.0.0.3"}},
+			want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name: "exclusions",
+			spec: TargetSpec{
+				Targets: []string{"10.0.0.0/29"},
+				Exclude: []string{"10.0.0.0", "10.0.0.2-10.0.0.3,10.0.0.6/31"},
+			},
+			want: []string{"10.0.0.1", "10.0.0.4", "10.0.0.5"},
+		},
+		{
+			name: "octet range exclusion",
+			spec: TargetSpec{Targets: []string{"10.0.0.1-4"}, Exclude: []string{"10.0.0.*"}},
+		},
+		{
+			name: "hostname network",
+			spec: TargetSpec{Targets: []string{"localhost/30"}},
+			want: []string{"127.0.0.0", "127.0.0.1", "127.0.0.2", "127.0.0.3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := ParseTargets(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := slices.Collect(targets.All())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargetsErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		spec TargetSpec
+		want string
+	}{
+		{"no targets", TargetSpec{}, "no targets specified"},
+		{"bad prefix length", TargetSpec{Targets: []string{"10.0.0.0/x"}}, "invalid prefix length"},
+		{"prefix too long", TargetSpec{Targets: []string{"10.0.0.0/33"}}, "invalid CIDR"},
+		{"reversed range", TargetSpec{Targets: []string{"10.0.0.9-10.0.0.1"}}, "invalid address range"},
+		{"mixed range", TargetSpec{Targets: []string{"10.0.0.1-::1"}}, "invalid"},
+		{"octet out of range", TargetSpec{Targets: []string{"10.0.0.1-256"}}, "invalid octet"},
+		{"bad exclusion", TargetSpec{Targets: []string{"10.0.0.1"}, Exclude: []string{"10.0.0.0/40"}}, "exclusion:"},
+		{"missing input file", TargetSpec{InputFile: filepath.Join(dir, "missing")}, "no such file"},
+		{"input file is a directory", TargetSpec{InputFile: dir}, "is a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTargets(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "targets")
+	exclude := filepath.Join(dir, "exclude")
+	os.WriteFile(input, []byte("10.0.0.1 10.0.0.2 # comment\nnot/a/target\n10.0.0.3,10.0.0.4\n"), 0o644)
+	os.WriteFile(exclude, []byte("# skip these\n10.0.0.2\n10.0.0.4\n"), 0o644)
+
+	targets, err := ParseTargets(TargetSpec{Targets: []string{"10.0.0.9"}, InputFile: input, ExcludeFile: exclude})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Collect(targets.All())
+	if want := []string{"10.0.0.9", "10.0.0.1", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+}
+
+func TestRandomTargets(t *testing.T) {
+	targets, err := ParseTargets(TargetSpec{Targets: []string{"10.0.0.0/24"}, Random: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Collect(targets.All())
+	if len(got) != 256 {
+		t.Fatalf("got %d targets, want 256", len(got))
+	}
+	slices.Sort(got)
+	if len(slices.Compact(got)) != 256 {
+		t.Errorf("random order repeated targets")
+	}
+}
+
+func TestHostnameNetwork(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs []string
+		want  []string
+	}{
+		{"ipv6 first", []string{"2001:db8::1", "192.0.2.9"}, []string{"192.0.2.8", "192.0.2.9", "192.0.2.10", "192.0.2.11"}},
+		{"ipv6 only", []string{"2001:db8::1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &hostnameTarget{name: "example.test", bits: 30}
+			var addrs []netip.Addr
+			for _, a := range tt.addrs {
+				addrs = append(addrs, netip.MustParseAddr(a))
+			}
+			h.setAddrs(addrs)
+
+			var got []string
+			h.each(func(addr netip.Addr) bool {
+				got = append(got, addr.String())
+				return true
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %v, want %v", got, tt.want)
+			}
+			if h.contains(netip.MustParseAddr("2001:db8::1")) {
+				t.Errorf("network contains the IPv6 address")
+			}
+		})
+	}
+}
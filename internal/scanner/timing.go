@@ -0,0 +1,202 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimingTemplate sets how patiently and how hard a host is probed, after
+// nmap's -T0 (paranoid) to -T5 (insane).
+type TimingTemplate struct {
+	Name              string
+	InitialRTTTimeout time.Duration
+	MinRTTTimeout     time.Duration
+	MaxRTTTimeout     time.Duration
+	MaxRetries        int
+	ScanDelay         time.Duration // between probes to the same host
+	MaxScanDelay      time.Duration // how far lost probes may raise ScanDelay
+	MaxParallelism    int           // probes in flight per host
+}
+
+var TimingTemplates = []TimingTemplate{
+	{"paranoid", 5 * time.Minute, 100 * time.Millisecond, 10 * time.Minute, 10, 5 * time.Minute, time.Second, 1},
+	{"sneaky", 15 * time.Second, 100 * time.Millisecond, 15 * time.Second, 10, 15 * time.Second, time.Second, 1},
+	{"polite", time.Second, 100 * time.Millisecond, 10 * time.Second, 10, 400 * time.Millisecond, time.Second, 1},
+	{"normal", time.Second, 100 * time.Millisecond, 10 * time.Second, 10, 0, time.Second, 100},
+	{"aggressive", 500 * time.Millisecond, 100 * time.Millisecond, 1250 * time.Millisecond, 6, 0, 10 * time.Millisecond, 300},
+	{"insane", 250 * time.Millisecond, 50 * time.Millisecond, 300 * time.Millisecond, 2, 0, 5 * time.Millisecond, 500},
+}
+
+// ParseTiming accepts a template number ("4", "T4") or name ("aggressive").
+func ParseTiming(s string) (TimingTemplate, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if n, err := strconv.Atoi(strings.TrimPrefix(s, "t")); err == nil {
+		if n < 0 || n >= len(TimingTemplates) {
+			return TimingTemplate{}, fmt.Errorf("timing template %d out of range 0-5", n)
+		}
+		return TimingTemplates[n], nil
+	}
+	for _, t := range TimingTemplates {
+		if t.Name == s {
+			return t, nil
+		}
+	}
+	return TimingTemplate{}, fmt.Errorf("unknown timing template %q", s)
+}
+
+// hostTiming adapts probe timeouts, parallelism and retransmissions to
+// the response times observed from one host. Timeouts follow the
+// smoothed RTT as in RFC 6298. Parallelism grows with every answer. When
+// only a retransmission got through, probes are being lost, so
+// parallelism halves and retries and the scan delay go up.
+type hostTiming struct {
+	tmpl   TimingTemplate
+	global *rateLimiter
+	local  *rateLimiter
+
+	mu        sync.Mutex
+	srtt      time.Duration
+	rttvar    time.Duration
+	cwnd      float64
+	ceiling   int
+	inFlight  int
+	retries   int
+	delay     time.Duration
+	nextProbe time.Time
+}
+
+func (s *Scanner) newHostTiming() *hostTiming {
+	h := &hostTiming{
+		tmpl:    s.timing,
+		global:  s.limiter,
+		local:   newRateLimiter(s.hostRateLimit),
+		ceiling: min(s.timing.MaxParallelism, s.maxWorkers),
+		retries: min(s.retries, s.timing.MaxRetries),
+		delay:   s.timing.ScanDelay,
+	}
+	h.cwnd = float64(min(10, h.ceiling))
+	return h
+}
+
+// tryAcquire takes a slot in the host's window of probes in flight,
+// reporting false if it is full.
+func (h *hostTiming) tryAcquire() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.inFlight >= int(h.cwnd) {
+		return false
+	}
+	h.inFlight++
+	return true
+}
+
+func (h *hostTiming) release() {
+	h.mu.Lock()
+	h.inFlight--
+	h.mu.Unlock()
+}
+
+// send blocks until a probe or retransmission may go out under the scan
+// delay and the host and global rate limits.
+func (h *hostTiming) send() {
+	h.mu.Lock()
+	var wait time.Duration
+	if h.delay > 0 {
+		now := time.Now()
+		if h.nextProbe.After(now) {
+			wait = h.nextProbe.Sub(now)
+		}
+		h.nextProbe = now.Add(wait + h.delay)
+	}
+	h.mu.Unlock()
+
+	time.Sleep(wait)
+	h.local.wait()
+	h.global.wait()
+}
+
+func (h *hostTiming) timeout() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.srtt == 0 {
+		return h.tmpl.InitialRTTTimeout
+	}
+	return min(max(h.srtt+4*h.rttvar, h.tmpl.MinRTTTimeout), h.tmpl.MaxRTTTimeout)
+}
+
+func (h *hostTiming) maxRetries() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retries
+}
+
+// observe records an answered probe. retransmitted reports whether the
+// answer came only after resending.
+func (h *hostTiming) observe(rtt time.Duration, retransmitted bool) {
+	h.mu.Lock()
+	if h.srtt == 0 {
+		h.srtt, h.rttvar = rtt, rtt/2
+	} else {
+		diff := h.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		h.rttvar = (3*h.rttvar + diff) / 4
+		h.srtt = (7*h.srtt + rtt) / 8
+	}
+
+	if retransmitted {
+		h.cwnd = max(h.cwnd/2, 1)
+		h.retries = min(h.retries+1, h.tmpl.MaxRetries)
+		if h.delay < h.tmpl.MaxScanDelay {
+			h.delay = min(max(2*h.delay, 5*time.Millisecond), h.tmpl.MaxScanDelay)
+		}
+	} else {
+		h.cwnd = min(h.cwnd+1, float64(h.ceiling))
+	}
+	h.mu.Unlock()
+}
+
+// rateLimiter is a token bucket refilled with one token per interval and
+// holding up to a tenth of a second's worth. A nil limiter never waits.
+type rateLimiter struct {
+	interval time.Duration
+	burst    float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	if interval <= 0 {
+		return nil
+	}
+	burst := max(1, float64(100*time.Millisecond/interval))
+	return &rateLimiter{interval: interval, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait takes a token, sleeping until one is due if the bucket is empty.
+func (r *rateLimiter) wait() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	r.tokens = min(r.burst, r.tokens+float64(now.Sub(r.last))/float64(r.interval))
+	r.last = now
+	r.tokens--
+	var wait time.Duration
+	if r.tokens < 0 {
+		wait = time.Duration(-r.tokens * float64(r.interval))
+	}
+	r.mu.Unlock()
+
+	time.Sleep(wait)
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// udpPayloads are probes that get a reply from common UDP services, which
+// ignore empty datagrams. Other ports are sent an empty datagram.
+var udpPayloads = map[int][]byte{
+	53:   dnsProbe,
+	69:   []byte("\x00\x01r7tftp.txt\x00octet\x00"),
+	123:  append([]byte{0xe3}, make([]byte, 47)...),
+	137:  []byte("\x80\xf0\x00\x10\x00\x01\x00\x00\x00\x00\x00\x00\x20CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00\x21\x00\x01"),
+	161:  snmpProbe,
+	1900: []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"),
+	5353: dnsProbe,
+}
+
+var (
+	// version.bind TXT CH query
+	dnsProbe = []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07version\x04bind\x00\x00\x10\x00\x03")
+
+	// SNMPv1 get-request for sysDescr.0 with community "public"
+	snmpProbe = []byte("\x30\x29\x02\x01\x00\x04\x06public\xa0\x1c\x02\x04\x4e\x43\x45\x58\x02\x01\x00\x02\x01\x00" +
+		"\x30\x0e\x30\x0c\x06\x08\x2b\x06\x01\x02\x01\x01\x01\x00\x05\x00")
+)
+
+func (s *Scanner) scanUDPPort(host string, port int, timing *hostTiming) ScanResult {
+	start := time.Now()
+	state := s.probeUDP(host, port, timing)
+
+	return ScanResult{
+		Host:         host,
+		Port:         port,
+		Protocol:     "udp",
+		Service:      s.getServiceName(port),
+		Open:         state == "open",
+		Filtered:     state != "open" && state != "closed",
+		State:        state,
+		ResponseTime: time.Since(start),
+		Timestamp:    time.Now(),
+	}
+}
+
+// probeUDP sends the port's probe, resending as the host's timing
+// allows. Any reply means open and an ICMP port unreachable, which the
+// kernel reports as ECONNREFUSED on a connected socket, means closed.
+// Silence leaves the port open|filtered, as there is no telling a dropped
+// probe from a service that ignored it.
+func (s *Scanner) probeUDP(host string, port int, timing *hostTiming) string {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(host, strconv.Itoa(port)), s.timeout)
+	if err != nil {
+		s.logger.Debug("udp probe failed", "host", host, "port", port, "error", err)
+		return dialErrorState(err)
+	}
+	defer conn.Close()
+
+	payload := udpPayloads[port]
+	buf := make([]byte, 2048)
+	for attempt := 0; attempt <= timing.maxRetries(); attempt++ {
+		timing.send()
+		start := time.Now()
+		if _, err := conn.Write(payload); err != nil {
+			return dialErrorState(err)
+		}
+
+		conn.SetReadDeadline(start.Add(timing.timeout()))
+		_, err := conn.Read(buf)
+		if err == nil {
+			timing.observe(time.Since(start), attempt > 0)
+			return "open"
+		}
+		if state := dialErrorState(err); state != "filtered" {
+			timing.observe(time.Since(start), attempt > 0)
+			return state
+		}
+	}
+	return "open|filtered"
+}
@@ -0,0 +1,278 @@
+package scanner
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultVersionIntensity skips only the rarest probes, as nmap does.
+	defaultVersionIntensity = 7
+
+	// maxProbeReply caps how much of a reply is read and matched.
+	maxProbeReply = 64 * 1024
+)
+
+// VersionIntensityZero asks for version intensity 0, as a zero
+// ScannerConfig.VersionIntensity means the default: only NULL and the
+// probes registered for a port are sent.
+const VersionIntensityZero = -1
+
+var builtinServiceProbes = sync.OnceValue(func() []*ServiceProbe {
+	probes, err := ParseServiceProbes(strings.NewReader(defaultServiceProbes))
+	if err != nil {
+		panic(fmt.Sprintf("built-in service probes: %v", err))
+	}
+	return probes
+})
+
+// loadServiceProbes returns the built-in probes, extended by those in
+// file if it is set.
+func loadServiceProbes(file string) ([]*ServiceProbe, error) {
+	probes := builtinServiceProbes()
+	if file == "" {
+		return probes, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	extra, err := ParseServiceProbes(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return mergeServiceProbes(probes, extra), nil
+}
+
+// detectVersion identifies the service on an open TCP port and fills in
+// what it learned. Ports that match nothing keep their banner, if any.
+func (s *Scanner) detectVersion(result *ScanResult) {
+	info, banner := s.probeService(result.Host, result.Port, false)
+	if ((info == nil || info.Soft) && s.sslPort(result.Port)) || (info != nil && info.Name == "ssl") {
+		if tlsInfo, tlsBanner := s.probeService(result.Host, result.Port, true); tlsInfo != nil {
+			info, banner = tlsInfo, tlsBanner
+			info.TLS = true
+		}
+	}
+
+	result.Banner = banner
+	if info == nil {
+		return
+	}
+	result.Service = info.Name
+	if info.TLS {
+		result.Service = "ssl/" + info.Name
+	}
+	result.Product = info.Product
+	result.Version = info.Version
+	result.ExtraInfo = info.Info
+	result.CPE = info.CPE
+}
+
+// probeService sends probes in order until a reply matches. A soft match
+// names the service; after one only probes that can match that service
+// are sent, looking for its version.
+func (s *Scanner) probeService(host string, port int, useTLS bool) (*ServiceInfo, string) {
+	var soft *ServiceInfo
+	var banner string
+	for _, probe := range s.probeOrder(port) {
+		if soft != nil && !s.canMatch(probe, soft.Name) {
+			continue
+		}
+
+		var info *ServiceInfo
+		reply, err := s.sendProbe(host, port, probe, useTLS, func(reply string) bool {
+			info = s.matchReply(probe, reply)
+			return info != nil && !info.Soft
+		})
+		if err != nil {
+			s.logger.Debug("service probe failed", "host", host, "port", port, "probe", probe.Name, "error", err)
+			break
+		}
+		if banner == "" {
+			banner = printable(reply)
+		}
+
+		if info != nil && !info.Soft {
+			return info, banner
+		}
+		if info != nil && soft == nil {
+			soft = info
+		}
+	}
+	return soft, banner
+}
+
+// probeOrder lists the TCP probes to try on port: NULL, those registered
+// for the port, then the rest within the version intensity, each in
+// database order.
+func (s *Scanner) probeOrder(port int) []*ServiceProbe {
+	var null, registered, rest []*ServiceProbe
+	for _, probe := range s.probes {
+		switch {
+		case probe.Protocol != "TCP":
+		case probe.Name == "NULL":
+			null = append(null, probe)
+		case probe.Ports[port] || probe.SSLPorts[port]:
+			registered = append(registered, probe)
+		case probe.Rarity <= s.versionIntensity:
+			rest = append(rest, probe)
+		}
+	}
+	return append(append(null, registered...), rest...)
+}
+
+func (s *Scanner) sslPort(port int) bool {
+	for _, probe := range s.probes {
+		if probe.SSLPorts[port] {
+			return true
+		}
+	}
+	return false
+}
+
+// canMatch reports whether a reply to probe could be matched as service.
+func (s *Scanner) canMatch(probe *ServiceProbe, service string) bool {
+	for _, p := range s.matchProbes(probe) {
+		for _, m := range p.Matches {
+			if m.Service == service && !m.Soft {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchProbes lists the probes whose matches apply to replies to probe:
+// itself, its fallbacks and NULL.
+func (s *Scanner) matchProbes(probe *ServiceProbe) []*ServiceProbe {
+	probes := []*ServiceProbe{probe}
+	for _, name := range probe.Fallback {
+		if p := findProbe(s.probes, probe.Protocol, name); p != nil && p != probe {
+			probes = append(probes, p)
+		}
+	}
+	if null := findProbe(s.probes, probe.Protocol, "NULL"); null != nil && null != probe {
+		probes = append(probes, null)
+	}
+	return probes
+}
+
+// matchReply returns the first hard match for reply, or else the first
+// soft match.
+func (s *Scanner) matchReply(probe *ServiceProbe, reply string) *ServiceInfo {
+	var soft *ServiceInfo
+	for _, p := range s.matchProbes(probe) {
+		for _, m := range p.Matches {
+			info := m.match(reply)
+			if info == nil {
+				continue
+			}
+			if !info.Soft {
+				return info
+			}
+			if soft == nil {
+				soft = info
+			}
+		}
+	}
+	return soft
+}
+
+// sendProbe sends probe on a new connection and reads the reply until
+// done accepts it, the probe's wait runs out or the service closes the
+// connection. done sees the reply with each byte as one character.
+func (s *Scanner) sendProbe(host string, port int, probe *ServiceProbe, useTLS bool, done func(string) bool) (string, error) {
+	s.limiter.wait()
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(probe.TotalWait)
+	conn.SetDeadline(deadline)
+	if len(probe.Payload) > 0 {
+		if _, err := conn.Write(probe.Payload); err != nil {
+			// the service hung up; later probes may fare better
+			return "", nil
+		}
+	}
+
+	var reply []byte
+	buf := make([]byte, 4096)
+	for len(reply) < maxProbeReply {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			reply = append(reply, buf[:n]...)
+			if done(latin1(reply)) {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return string(reply), nil
+}
+
+// printable trims a reply for display as a banner.
+func printable(reply string) string {
+	banner := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, reply)
+	banner = strings.TrimSpace(banner)
+	if len(banner) > 1024 {
+		banner = banner[:1024]
+	}
+	return banner
+}
+
+// ServiceVersion describes the service as "product version (info)", or
+// by its banner when version detection did not recognise it.
+func (r ScanResult) ServiceVersion() string {
+	var parts []string
+	for _, part := range []string{r.Product, r.Version} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if r.ExtraInfo != "" {
+		parts = append(parts, "("+r.ExtraInfo+")")
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, " ")
+	}
+
+	if r.Banner == "" {
+		return ""
+	}
+	banner := r.Banner
+	if len(banner) > 50 {
+		banner = banner[:50] + "..."
+	}
+	return "(" + banner + ")"
+}
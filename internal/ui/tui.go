@@ -126,7 +126,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			resultStr := fmt.Sprintf("Found %d open ports:\n", len(msg.results))
 			for _, result := range msg.results {
-				resultStr += fmt.Sprintf("%d/tcp %s %s\n", result.Port, result.Service, result.Version)
+				resultStr += fmt.Sprintf("%d/tcp %s %s\n", result.Port, result.Service, result.ServiceVersion())
 			}
 			m.result = resultStr
 		}
@@ -262,11 +262,15 @@ func (m Model) connectToHost(hostPort string) tea.Cmd {
 
 func (m Model) scanHost(target string) tea.Cmd {
 	return func() tea.Msg {
-		scanner := scanner.New(scanner.ScannerConfig{
+		scanner, err := scanner.New(scanner.ScannerConfig{
 			Timeout: time.Second * 5,
 			Verbose: true,
 			Version: true,
 		})
+		if err != nil {
+			return errorMsg{err: fmt.Sprintf("Scan failed: %v", err)}
+		}
+		defer scanner.Close()
 		results := scanner.ScanHostWithResults(target, "1-1000")
 		return scanResultMsg{results: results}
 	}